@@ -0,0 +1,212 @@
+// Package announce renders PR buddy pairing results as Discord messages.
+//
+// It is kept apart from the Discord session so the output can be tested: the
+// caller supplies a Lookup for members' display names and avatars and sends
+// whatever Embeds or Text returns.
+package announce
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/prbuddy"
+)
+
+// Color is the accent colour of pairing embeds.
+const Color = 0x5865F2
+
+// maxPairEmbeds is how many pairs get their own embed. Discord allows ten
+// embeds per message and the first is the header.
+const maxPairEmbeds = 9
+
+// Profile is how a member appears in an announcement.
+type Profile struct {
+	// Name is the member's display name.
+	Name string
+	// AvatarURL is the member's avatar image, or "" for none.
+	AvatarURL string
+}
+
+// Lookup returns the profile for a user ID. It may return a zero Profile, in
+// which case the member's PR buddy name is used.
+type Lookup func(userID string) Profile
+
+// Options carries the context a Result doesn't.
+type Options struct {
+	// Lookup resolves member profiles. Nil uses PR buddy names only.
+	Lookup Lookup
+	// NextRun is when pairings will next be generated. Zero omits it.
+	NextRun time.Time
+}
+
+// Embeds renders a Result as a header embed followed by one embed per pair,
+// showing both members' avatars. Pairs beyond what fits in one message are
+// listed in the header instead.
+func Embeds(result prbuddy.Result, opts Options) []*discordgo.MessageEmbed {
+	header := &discordgo.MessageEmbed{
+		Title:       "PR Buddy pairings",
+		Description: "Week of " + WeekRange(result.Week),
+		Color:       Color,
+	}
+	embeds := []*discordgo.MessageEmbed{header}
+
+	if len(result.Pairs) == 0 {
+		header.Description += "\nNo pairings this week — not enough available developers."
+	}
+
+	for idx, p := range result.Pairs {
+		a, b := profile(opts, p.A), profile(opts, p.B)
+		line := fmt.Sprintf("<@%s>%s ↔ <@%s>%s",
+			p.A.UserID, doneMark(result, p.A.UserID), p.B.UserID, doneMark(result, p.B.UserID))
+		if len(result.Pairs) > maxPairEmbeds {
+			header.Fields = append(header.Fields, &discordgo.MessageEmbedField{
+				Name:  fmt.Sprintf("%d. %s ↔ %s", idx+1, a.Name, b.Name),
+				Value: line,
+			})
+			continue
+		}
+		embed := &discordgo.MessageEmbed{
+			Author:      &discordgo.MessageEmbedAuthor{Name: fmt.Sprintf("%d. %s ↔ %s", idx+1, a.Name, b.Name), IconURL: a.AvatarURL},
+			Description: line,
+			Color:       Color,
+		}
+		if b.AvatarURL != "" {
+			embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: b.AvatarURL}
+		}
+		embeds = append(embeds, embed)
+	}
+
+	if result.SittingOut != nil {
+		header.Fields = append(header.Fields, &discordgo.MessageEmbedField{
+			Name:   "Sitting out",
+			Value:  mention(opts, result.SittingOut),
+			Inline: true,
+		})
+	}
+	if len(result.OnPTO) > 0 {
+		away := make([]string, len(result.OnPTO))
+		for idx, m := range result.OnPTO {
+			away[idx] = mention(opts, m)
+		}
+		header.Fields = append(header.Fields, &discordgo.MessageEmbedField{
+			Name:   "On PTO",
+			Value:  strings.Join(away, "\n"),
+			Inline: true,
+		})
+	}
+
+	if !opts.NextRun.IsZero() {
+		embeds[len(embeds)-1].Footer = &discordgo.MessageEmbedFooter{
+			Text: "Next pairings " + opts.NextRun.Format("Mon Jan 2, 15:04 MST"),
+		}
+	}
+	return embeds
+}
+
+// Text renders a Result as a plain markdown message, for when embeds can't be
+// sent.
+func Text(result prbuddy.Result) string {
+	if len(result.Pairs) == 0 {
+		return fmt.Sprintf("No PR buddy pairings this week (%s) — not enough available developers.",
+			result.Week.Format("Jan 2, 2006"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**PR Buddy pairings — week of %s**\n", result.Week.Format("Jan 2, 2006")))
+	for idx, p := range result.Pairs {
+		sb.WriteString(fmt.Sprintf("%d. <@%s>%s ↔ <@%s>%s\n", idx+1,
+			p.A.UserID, doneMark(result, p.A.UserID), p.B.UserID, doneMark(result, p.B.UserID)))
+	}
+	if result.SittingOut != nil {
+		sb.WriteString(fmt.Sprintf("\n_<@%s> is sitting out this week._", result.SittingOut.UserID))
+	}
+	return sb.String()
+}
+
+// Mentions renders a line mentioning everyone in a Result, for the content of
+// an embed announcement: mentions inside embeds don't notify anyone.
+func Mentions(result prbuddy.Result) string {
+	userIDs := Mentioned(result)
+	mentions := make([]string, len(userIDs))
+	for idx, userID := range userIDs {
+		mentions[idx] = fmt.Sprintf("<@%s>", userID)
+	}
+	return strings.Join(mentions, " ")
+}
+
+// Mentioned returns the user IDs an announcement of result mentions: both
+// members of each pair, then whoever is sitting out. Callers pass it as the
+// allowed mentions so nothing else in the message pings.
+func Mentioned(result prbuddy.Result) []string {
+	var userIDs []string
+	for _, p := range result.Pairs {
+		userIDs = append(userIDs, p.A.UserID, p.B.UserID)
+	}
+	if result.SittingOut != nil {
+		userIDs = append(userIDs, result.SittingOut.UserID)
+	}
+	return userIDs
+}
+
+// ReminderText renders a mid-week check-in that pings pending members.
+func ReminderText(reminder prbuddy.Reminder) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⏰ **PR buddy check-in — week of %s**\n", reminder.Week.Format("Jan 2, 2006")))
+	sb.WriteString("Haven't looked at your pairing yet? Say hi to your buddy, then hit **Got it** or **Done reviewing** on Monday's post.\n")
+	for _, p := range reminder.Pairs {
+		sb.WriteString(fmt.Sprintf("• %s ↔ %s\n", pendingMention(reminder, p.A), pendingMention(reminder, p.B)))
+	}
+	return sb.String()
+}
+
+// WeekRange renders the working days of the week starting monday, e.g.
+// "Apr 6 – Apr 10, 2026".
+func WeekRange(monday time.Time) string {
+	friday := monday.AddDate(0, 0, 4)
+	return fmt.Sprintf("%s – %s", monday.Format("Jan 2"), friday.Format("Jan 2, 2006"))
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// profile resolves a member's profile, falling back to their PR buddy name.
+func profile(opts Options, m *prbuddy.Member) Profile {
+	var p Profile
+	if opts.Lookup != nil {
+		p = opts.Lookup(m.UserID)
+	}
+	if p.Name == "" {
+		p.Name = m.Name
+	}
+	if p.Name == "" {
+		p.Name = m.UserID
+	}
+	return p
+}
+
+// mention renders a member as a mention followed by their display name.
+func mention(opts Options, m *prbuddy.Member) string {
+	return fmt.Sprintf("<@%s> (%s)", m.UserID, profile(opts, m).Name)
+}
+
+// pendingMention mentions pending members and names everyone else, so only
+// those who haven't checked in are pinged.
+func pendingMention(reminder prbuddy.Reminder, m *prbuddy.Member) string {
+	if slices.Contains(reminder.Pending, m.UserID) {
+		return fmt.Sprintf("<@%s>", m.UserID)
+	}
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.UserID
+}
+
+// doneMark returns a tick for members who have finished reviewing.
+func doneMark(result prbuddy.Result, userID string) string {
+	if slices.Contains(result.Done, userID) {
+		return " ✅"
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package announce
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbarber/deskbot/prbuddy"
+)
+
+var monday = time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+
+func member(id, name string) *prbuddy.Member {
+	return &prbuddy.Member{UserID: id, Name: name}
+}
+
+// --- Embeds -----------------------------------------------------------------
+
+func TestEmbeds_PairsGetTheirOwnEmbeds(t *testing.T) {
+	result := prbuddy.Result{
+		Week: monday,
+		Pairs: []prbuddy.Pair{
+			{A: member("u1", "alice"), B: member("u2", "bob")},
+			{A: member("u3", "carol"), B: member("u4", "dave")},
+		},
+		Done: []string{"u2"},
+	}
+	lookup := func(userID string) Profile {
+		if userID == "u1" {
+			return Profile{Name: "Alice A.", AvatarURL: "https://cdn.example/u1.png"}
+		}
+		return Profile{}
+	}
+
+	embeds := Embeds(result, Options{Lookup: lookup})
+
+	if len(embeds) != 3 {
+		t.Fatalf("want header + 2 pair embeds, got %d", len(embeds))
+	}
+	first := embeds[1]
+	if first.Author == nil || first.Author.Name != "1. Alice A. ↔ bob" || first.Author.IconURL != "https://cdn.example/u1.png" {
+		t.Errorf("unexpected author: %+v", first.Author)
+	}
+	if first.Thumbnail != nil {
+		t.Errorf("want no thumbnail without an avatar, got %+v", first.Thumbnail)
+	}
+	if first.Description != "<@u1> ↔ <@u2> ✅" {
+		t.Errorf("unexpected description: %q", first.Description)
+	}
+	if !strings.Contains(embeds[0].Description, "Apr 6 – Apr 10, 2026") {
+		t.Errorf("header missing week range: %q", embeds[0].Description)
+	}
+}
+
+func TestEmbeds_SittingOutAndPTO(t *testing.T) {
+	result := prbuddy.Result{
+		Week:       monday,
+		Pairs:      []prbuddy.Pair{{A: member("u1", "alice"), B: member("u2", "bob")}},
+		SittingOut: member("u3", "carol"),
+		OnPTO:      []*prbuddy.Member{member("u4", "dave"), member("u5", "erin")},
+	}
+
+	header := Embeds(result, Options{})[0]
+
+	if len(header.Fields) != 2 {
+		t.Fatalf("want sitting out and PTO fields, got %d", len(header.Fields))
+	}
+	if got := header.Fields[0].Value; got != "<@u3> (carol)" {
+		t.Errorf("sitting out: got %q", got)
+	}
+	if got := header.Fields[1].Value; got != "<@u4> (dave)\n<@u5> (erin)" {
+		t.Errorf("on PTO: got %q", got)
+	}
+}
+
+func TestEmbeds_FooterOnLastEmbed(t *testing.T) {
+	result := prbuddy.Result{
+		Week:  monday,
+		Pairs: []prbuddy.Pair{{A: member("u1", "alice"), B: member("u2", "bob")}},
+	}
+	next := time.Date(2026, 4, 13, 9, 0, 0, 0, time.UTC)
+
+	embeds := Embeds(result, Options{NextRun: next})
+
+	if embeds[0].Footer != nil {
+		t.Error("header should not carry the footer")
+	}
+	if f := embeds[len(embeds)-1].Footer; f == nil || !strings.Contains(f.Text, "Mon Apr 13") {
+		t.Errorf("unexpected footer: %+v", f)
+	}
+}
+
+func TestEmbeds_ManyPairsListedInHeader(t *testing.T) {
+	result := prbuddy.Result{Week: monday}
+	for i := 0; i < maxPairEmbeds+1; i++ {
+		result.Pairs = append(result.Pairs, prbuddy.Pair{A: member("a", "a"), B: member("b", "b")})
+	}
+
+	embeds := Embeds(result, Options{})
+
+	if len(embeds) != 1 {
+		t.Errorf("want only the header embed, got %d", len(embeds))
+	}
+	if len(embeds[0].Fields) != maxPairEmbeds+1 {
+		t.Errorf("want a field per pair, got %d", len(embeds[0].Fields))
+	}
+}
+
+func TestEmbeds_NoPairs(t *testing.T) {
+	embeds := Embeds(prbuddy.Result{Week: monday}, Options{})
+	if len(embeds) != 1 || !strings.Contains(embeds[0].Description, "No pairings") {
+		t.Errorf("unexpected embeds: %+v", embeds)
+	}
+}
+
+// --- Text -------------------------------------------------------------------
+
+func TestText(t *testing.T) {
+	result := prbuddy.Result{
+		Week:       monday,
+		Pairs:      []prbuddy.Pair{{A: member("u1", "alice"), B: member("u2", "bob")}},
+		SittingOut: member("u3", "carol"),
+		Done:       []string{"u1"},
+	}
+
+	want := "**PR Buddy pairings — week of Apr 6, 2026**\n" +
+		"1. <@u1> ✅ ↔ <@u2>\n" +
+		"\n_<@u3> is sitting out this week._"
+	if got := Text(result); got != want {
+		t.Errorf("Text:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestText_NoPairs(t *testing.T) {
+	if got := Text(prbuddy.Result{Week: monday}); !strings.HasPrefix(got, "No PR buddy pairings") {
+		t.Errorf("unexpected text: %q", got)
+	}
+}
+
+// --- Mentions ---------------------------------------------------------------
+
+func TestMentions(t *testing.T) {
+	result := prbuddy.Result{
+		Week: monday,
+		Pairs: []prbuddy.Pair{
+			{A: member("u1", "alice"), B: member("u2", "bob")},
+			{A: member("u4", "dave"), B: member("u5", "erin")},
+		},
+		SittingOut: member("u3", "carol"),
+	}
+
+	if got, want := Mentions(result), "<@u1> <@u2> <@u4> <@u5> <@u3>"; got != want {
+		t.Errorf("Mentions: got %q, want %q", got, want)
+	}
+	if got := Mentions(prbuddy.Result{Week: monday}); got != "" {
+		t.Errorf("Mentions with no pairs: got %q", got)
+	}
+}
+
+// --- ReminderText -----------------------------------------------------------
+
+func TestReminderText(t *testing.T) {
+	reminder := prbuddy.Reminder{
+		Week:    monday,
+		Pairs:   []prbuddy.Pair{{A: member("u1", "alice"), B: member("u2", "bob")}},
+		Pending: []string{"u2"},
+	}
+
+	got := ReminderText(reminder)
+	if !strings.Contains(got, "• alice ↔ <@u2>") {
+		t.Errorf("want only bob mentioned, got %q", got)
+	}
+	if !strings.Contains(got, "week of Apr 6, 2026") {
+		t.Errorf("missing week: %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Package audit describes changes made to a guild's bot settings and
+// remembers where each guild wants them announced.
+//
+// Posting entries to Discord is left to the caller; this package only
+// formats them and persists each guild's audit channel to a JSON file.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one change to a guild's bot state.
+type Entry struct {
+	// ActorID is the user who made the change, or "" when the bot made it
+	// on its own, e.g. a scheduled run or a new member joining.
+	ActorID string
+	// Action names the change, e.g. "PR buddy member added".
+	Action string
+	// Target is what was changed, as Discord markup such as "<@123>" or
+	// "<#456>". It may be empty for guild-wide changes.
+	Target string
+	// Before and After describe the changed value. Either may be empty, as
+	// for something newly created or removed.
+	Before string
+	After  string
+	// Time is when the change was made.
+	Time time.Time
+}
+
+// Text renders the entry as a Discord message, e.g.
+//
+//	**PR buddy PTO set** for <@2> by <@1> · <t:1775988000:f>
+//	Before: none
+//	After: 2026-04-13 → 2026-04-20
+func (e Entry) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**", e.Action)
+	if e.Target != "" {
+		fmt.Fprintf(&b, " for %s", e.Target)
+	}
+	if e.ActorID != "" {
+		fmt.Fprintf(&b, " by <@%s>", e.ActorID)
+	} else {
+		b.WriteString(" by the bot")
+	}
+	if !e.Time.IsZero() {
+		fmt.Fprintf(&b, " · <t:%d:f>", e.Time.Unix())
+	}
+	if e.Before != "" {
+		fmt.Fprintf(&b, "\nBefore: %s", e.Before)
+	}
+	if e.After != "" {
+		fmt.Fprintf(&b, "\nAfter: %s", e.After)
+	}
+	return b.String()
+}
+
+// Store remembers each guild's audit channel. It is safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	channels map[string]string // guild ID → channel ID
+}
+
+// New creates a Store that persists to the given file path.
+func New(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		channels: make(map[string]string),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetChannel makes channelID the guild's audit channel. An empty channelID
+// turns auditing off for the guild.
+func (s *Store) SetChannel(guildID, channelID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if channelID == "" {
+		delete(s.channels, guildID)
+	} else {
+		s.channels[guildID] = channelID
+	}
+	return s.save()
+}
+
+// Channel returns the guild's audit channel, or "" if auditing is off.
+func (s *Store) Channel(guildID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channels[guildID]
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// load reads persisted state from disk. A missing file is not an error.
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("audit: read %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, &s.channels); err != nil {
+		return fmt.Errorf("audit: parse %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// save atomically writes current state to disk.
+// Caller must hold s.mu.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.channels, "", "  ")
+	if err != nil {
+		return fmt.Errorf("audit: marshal state: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("audit: write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("audit: rename to %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// --- Entry ------------------------------------------------------------------
+
+func TestEntryText(t *testing.T) {
+	e := Entry{
+		ActorID: "1",
+		Action:  "PR buddy PTO set",
+		Target:  "<@2>",
+		Before:  "none",
+		After:   "2026-04-13 → 2026-04-20",
+		Time:    time.Unix(1775988000, 0),
+	}
+	want := "**PR buddy PTO set** for <@2> by <@1> · <t:1775988000:f>\n" +
+		"Before: none\n" +
+		"After: 2026-04-13 → 2026-04-20"
+	if got := e.Text(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEntryText_BotWithoutValues(t *testing.T) {
+	e := Entry{Action: "Pairings generated"}
+	if got, want := e.Text(), "**Pairings generated** by the bot"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// --- Store ------------------------------------------------------------------
+
+func TestSetChannel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if got := s.Channel("g1"); got != "" {
+		t.Errorf("unset channel: got %q", got)
+	}
+	if err := s.SetChannel("g1", "c1"); err != nil {
+		t.Fatalf("SetChannel: %v", err)
+	}
+	if got := s.Channel("g1"); got != "c1" {
+		t.Errorf("channel: got %q, want c1", got)
+	}
+	if got := s.Channel("g2"); got != "" {
+		t.Errorf("other guild: got %q", got)
+	}
+
+	reloaded, err := New(path)
+	if err != nil {
+		t.Fatalf("New (reload): %v", err)
+	}
+	if got := reloaded.Channel("g1"); got != "c1" {
+		t.Errorf("after reload: got %q, want c1", got)
+	}
+
+	if err := reloaded.SetChannel("g1", ""); err != nil {
+		t.Fatalf("SetChannel off: %v", err)
+	}
+	if got := reloaded.Channel("g1"); got != "" {
+		t.Errorf("after turning off: got %q", got)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/audit"
+	"github.com/cbarber/deskbot/prbuddy"
+)
+
+// ---------------------------------------------------------------------------
+// Audit log
+// ---------------------------------------------------------------------------
+
+var auditLog *audit.Store
+
+var auditCommand = &discordgo.ApplicationCommand{
+	Name:        "audit",
+	Description: "Admins only: announce changes to the bot's settings in a channel",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Name:        "channel",
+			Description: "Post every change to the team, PTO, pairings and desks in a channel",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:         "channel",
+					Description:  "The channel to post changes in",
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+					Required:     true,
+				},
+			},
+		},
+		{
+			Name:        "off",
+			Description: "Stop posting changes",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+	},
+}
+
+func handleAudit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	opts := i.ApplicationCommandData().Options
+	if len(opts) == 0 {
+		respond(s, i, "Unknown audit subcommand.")
+		return
+	}
+	if !isAdmin(i) {
+		respond(s, i, "Only server managers can change the audit channel.")
+		return
+	}
+
+	before := channelMention(auditLog.Channel(i.GuildID))
+	switch opts[0].Name {
+	case "channel":
+		channel := opts[0].Options[0].ChannelValue(s)
+		if err := auditLog.SetChannel(i.GuildID, channel.ID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to set the audit channel: %v", err))
+			return
+		}
+		respond(s, i, fmt.Sprintf("Changes will be posted in <#%s>.", channel.ID))
+		auditInteraction(s, i, "Audit channel set", "", before, channelMention(channel.ID))
+
+	case "off":
+		// Announce it while there is still somewhere to announce it.
+		auditInteraction(s, i, "Audit channel turned off", "", before, "none")
+		if err := auditLog.SetChannel(i.GuildID, ""); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to turn off the audit channel: %v", err))
+			return
+		}
+		respond(s, i, "Changes will no longer be posted.")
+
+	default:
+		respond(s, i, "Unknown audit subcommand.")
+	}
+}
+
+// recordAudit logs a change and posts it to the guild's audit channel, if it
+// has one.
+func recordAudit(s *discordgo.Session, guildID string, e audit.Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	logger.Info("audit", "guild", guildID, "actor", e.ActorID, "action", e.Action,
+		"target", e.Target, "before", e.Before, "after", e.After)
+
+	channelID := auditLog.Channel(guildID)
+	if channelID == "" {
+		return
+	}
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         e.Text(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		logger.Error("Failed to post audit entry", "guild", guildID, "channel", channelID, "err", err)
+	}
+}
+
+// auditInteraction records a change made by whoever used the interaction.
+func auditInteraction(s *discordgo.Session, i *discordgo.InteractionCreate, action, target, before, after string) {
+	var actorID string
+	if user := interactionUser(i); user != nil {
+		actorID = user.ID
+	}
+	recordAudit(s, i.GuildID, audit.Entry{
+		ActorID: actorID,
+		Action:  action,
+		Target:  target,
+		Before:  before,
+		After:   after,
+	})
+}
+
+// auditDeskCreated records the bot creating a member's desk.
+func auditDeskCreated(s *discordgo.Session, guildID, userID string) {
+	after := "created"
+	if desk, ok := deskStore.DeskByOwner(guildID, userID); ok {
+		after = channelMention(desk.ChannelID)
+	}
+	recordAudit(s, guildID, audit.Entry{Action: "Desk created", Target: userMention(userID), After: after})
+}
+
+// userMention formats a user ID as a mention.
+func userMention(userID string) string {
+	return fmt.Sprintf("<@%s>", userID)
+}
+
+// channelMention formats a channel ID as a mention, or "none".
+func channelMention(channelID string) string {
+	if channelID == "" {
+		return "none"
+	}
+	return fmt.Sprintf("<#%s>", channelID)
+}
+
+// buddyMember returns the guild's PR buddy team member with the given user
+// ID, or nil if they aren't on the team.
+func buddyMember(guildID, userID string) *prbuddy.Member {
+	for _, m := range buddy.Members(guildID) {
+		if m.UserID == userID {
+			return m
+		}
+	}
+	return nil
+}
+
+// formatPTO describes a member's PTO for the audit log.
+func formatPTO(m *prbuddy.Member) string {
+	if m == nil || m.PTO == nil {
+		return "none"
+	}
+	out := fmt.Sprintf("%s → %s", m.PTO.LeaveOn.Format(prbuddy.DateLayout), m.PTO.ReturnsOn.Format(prbuddy.DateLayout))
+	if m.PTO.Note != "" {
+		out += fmt.Sprintf(" (%s)", m.PTO.Note)
+	}
+	return out
+}
+
+// formatPairs summarises a week's pairings for the audit log.
+func formatPairs(result prbuddy.Result) string {
+	if len(result.Pairs) == 0 {
+		return "no pairs"
+	}
+	pairs := make([]string, len(result.Pairs))
+	for idx, p := range result.Pairs {
+		pairs[idx] = userMention(p.A.UserID) + " + " + userMention(p.B.UserID)
+	}
+	out := strings.Join(pairs, ", ")
+	if result.SittingOut != nil {
+		out += "; " + userMention(result.SittingOut.UserID) + " sitting out"
+	}
+	return out
+}
+
+// onOff describes a setting that is either on or off for the audit log.
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// scheduleDescription describes a PR buddy job's schedule for the audit log.
+func scheduleDescription(guildID, job string) string {
+	spec, ok := buddy.Schedule(guildID, job)
+	if !ok {
+		return "off"
+	}
+	return spec
+}
+
+// currentPairs summarises the guild's stored week for the audit log.
+func currentPairs(guildID string) string {
+	result, ok := buddy.CurrentWeek(guildID)
+	if !ok {
+		return "none"
+	}
+	return formatPairs(result)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ---------------------------------------------------------------------------
+// Slash command registration
+// ---------------------------------------------------------------------------
+
+// enabledCommands returns every slash command the bot serves with the
+// configured features. Anything registered that isn't listed is removed on
+// the next start.
+func enabledCommands() []*discordgo.ApplicationCommand {
+	commands := []*discordgo.ApplicationCommand{auditCommand}
+	if cfg.Features.PRBuddy {
+		commands = append(commands, prbuddyCommand)
+	}
+	if cfg.Features.Desks {
+		commands = append(commands, deskCommand)
+	}
+	return commands
+}
+
+// registerCommands makes the registered commands match enabledCommands,
+// globally or, when guildID is set, in that guild only. Guild commands update
+// instantly, whereas global ones can take up to an hour to propagate, so
+// nothing is sent when the registered commands are already up to date.
+func registerCommands(s *discordgo.Session, guildID string) error {
+	appID := s.State.User.ID
+
+	commands := enabledCommands()
+	existing, err := s.ApplicationCommands(appID, guildID)
+	if err != nil {
+		return fmt.Errorf("list commands: %w", err)
+	}
+
+	added, changed, removed := diffCommands(existing, commands)
+	if len(added)+len(changed)+len(removed) == 0 {
+		logger.Info("Slash commands are up to date", "guild", guildID)
+		return nil
+	}
+	logger.Info("Updating slash commands", "guild", guildID, "added", added, "changed", changed, "removed", removed)
+
+	if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, commands); err != nil {
+		return fmt.Errorf("overwrite commands: %w", err)
+	}
+	return nil
+}
+
+// Switching between global and dev guild registration leaves the commands of
+// the scope no longer used behind, and Discord shows both sets in the dev
+// guild. The two functions below clean up after a switch.
+
+// removeLeftoverGuildCommands removes commands a development run registered
+// in the guild and didn't remove, e.g. because it crashed. It is used when
+// registering globally.
+func removeLeftoverGuildCommands(s *discordgo.Session, guildID string) {
+	existing, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	if err != nil {
+		logger.Error("Failed to list guild slash commands", "guild", guildID, "err", err)
+		return
+	}
+	if len(existing) > 0 {
+		logger.Info("Removing leftover dev guild slash commands", "guild", guildID, "count", len(existing))
+		unregisterCommands(s, guildID)
+	}
+}
+
+// removeLeftoverGlobalCommands removes the bot's global commands when
+// registering in a dev guild. A development bot may share its application
+// with the live one, so this only happens when remove is set, and otherwise
+// the leftovers are only reported.
+func removeLeftoverGlobalCommands(s *discordgo.Session, remove bool) {
+	existing, err := s.ApplicationCommands(s.State.User.ID, "")
+	if err != nil {
+		logger.Error("Failed to list global slash commands", "err", err)
+		return
+	}
+	switch {
+	case len(existing) == 0:
+	case remove:
+		logger.Info("Removing global slash commands", "count", len(existing))
+		unregisterCommands(s, "")
+	default:
+		logger.Warn("Global slash commands will show twice in the dev guild; run with -clear-global-commands to remove them", "count", len(existing))
+	}
+}
+
+// unregisterCommands removes every command from the guild, or every global
+// command when guildID is empty, so a development bot doesn't leave stale
+// commands behind.
+func unregisterCommands(s *discordgo.Session, guildID string) {
+	_, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, guildID, []*discordgo.ApplicationCommand{})
+	if err != nil {
+		logger.Error("Failed to unregister slash commands", "guild", guildID, "err", err)
+	}
+}
+
+// diffCommands returns the names of commands that want adds, changes or
+// drops compared to those already registered.
+func diffCommands(existing, want []*discordgo.ApplicationCommand) (added, changed, removed []string) {
+	registered := make(map[string]*discordgo.ApplicationCommand, len(existing))
+	for _, cmd := range existing {
+		registered[cmd.Name] = cmd
+	}
+
+	for _, cmd := range want {
+		old, ok := registered[cmd.Name]
+		switch {
+		case !ok:
+			added = append(added, cmd.Name)
+		case commandSignature(old) != commandSignature(cmd):
+			changed = append(changed, cmd.Name)
+		}
+		delete(registered, cmd.Name)
+	}
+	for name := range registered {
+		removed = append(removed, name)
+	}
+	slices.Sort(removed)
+	return added, changed, removed
+}
+
+// commandSignature renders the parts of a command a user can see, ignoring
+// the IDs and version Discord assigns on registration.
+func commandSignature(cmd *discordgo.ApplicationCommand) string {
+	data, err := json.Marshal(struct {
+		Name                     string                                `json:"name"`
+		Description              string                                `json:"description"`
+		DefaultMemberPermissions *int64                                `json:"default_member_permissions"`
+		Options                  []*discordgo.ApplicationCommandOption `json:"options"`
+	}{cmd.Name, cmd.Description, cmd.DefaultMemberPermissions, cmd.Options})
+	if err != nil {
+		// Treat an unrenderable command as changed.
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// testCommand builds a /desk-like command with a mode option, so cases can
+// tweak one part of it at a time.
+func testCommand(edit func(*discordgo.ApplicationCommand)) *discordgo.ApplicationCommand {
+	cmd := &discordgo.ApplicationCommand{
+		Name:        "desk",
+		Description: "Manage your desk",
+		Options: []*discordgo.ApplicationCommandOption{{
+			Name:        "mode",
+			Description: "Set who can see and join your desk",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{{
+				Name:        "mode",
+				Description: "Visibility mode",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    true,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "open", Value: "open"},
+					{Name: "knock", Value: "knock"},
+				},
+			}},
+		}},
+	}
+	if edit != nil {
+		edit(cmd)
+	}
+	return cmd
+}
+
+func TestDiffCommands(t *testing.T) {
+	other := &discordgo.ApplicationCommand{Name: "prbuddy", Description: "PR buddy pairing system"}
+
+	cases := []struct {
+		name                    string
+		existing, want          []*discordgo.ApplicationCommand
+		added, changed, removed []string
+	}{
+		{
+			name:  "nothing registered",
+			want:  []*discordgo.ApplicationCommand{testCommand(nil), other},
+			added: []string{"desk", "prbuddy"},
+		},
+		{
+			name:     "up to date",
+			existing: []*discordgo.ApplicationCommand{other, testCommand(nil)},
+			want:     []*discordgo.ApplicationCommand{testCommand(nil), other},
+		},
+		{
+			name: "IDs and versions from Discord are ignored",
+			existing: []*discordgo.ApplicationCommand{testCommand(func(c *discordgo.ApplicationCommand) {
+				c.ID, c.ApplicationID, c.Version = "123", "456", "789"
+			})},
+			want: []*discordgo.ApplicationCommand{testCommand(nil)},
+		},
+		{
+			name:     "changed",
+			existing: []*discordgo.ApplicationCommand{testCommand(nil), other},
+			want: []*discordgo.ApplicationCommand{testCommand(func(c *discordgo.ApplicationCommand) {
+				c.Description = "Manage your desk channel"
+			}), other},
+			changed: []string{"desk"},
+		},
+		{
+			name:     "removed",
+			existing: []*discordgo.ApplicationCommand{testCommand(nil), other, {Name: "audit"}},
+			want:     []*discordgo.ApplicationCommand{testCommand(nil)},
+			removed:  []string{"audit", "prbuddy"},
+		},
+		{
+			name:     "all at once",
+			existing: []*discordgo.ApplicationCommand{testCommand(nil), {Name: "audit"}},
+			want: []*discordgo.ApplicationCommand{testCommand(func(c *discordgo.ApplicationCommand) {
+				c.Options[0].Description = "Choose who can join"
+			}), other},
+			added:   []string{"prbuddy"},
+			changed: []string{"desk"},
+			removed: []string{"audit"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			added, changed, removed := diffCommands(tc.existing, tc.want)
+			if !slices.Equal(added, tc.added) {
+				t.Errorf("added: got %v, want %v", added, tc.added)
+			}
+			if !slices.Equal(changed, tc.changed) {
+				t.Errorf("changed: got %v, want %v", changed, tc.changed)
+			}
+			if !slices.Equal(removed, tc.removed) {
+				t.Errorf("removed: got %v, want %v", removed, tc.removed)
+			}
+		})
+	}
+}
+
+func TestCommandSignature(t *testing.T) {
+	base := commandSignature(testCommand(nil))
+	adminOnly := int64(discordgo.PermissionManageServer)
+
+	changes := map[string]func(*discordgo.ApplicationCommand){
+		"name":        func(c *discordgo.ApplicationCommand) { c.Name = "desks" },
+		"description": func(c *discordgo.ApplicationCommand) { c.Description = "Your desk" },
+		"permissions": func(c *discordgo.ApplicationCommand) { c.DefaultMemberPermissions = &adminOnly },
+		"option added": func(c *discordgo.ApplicationCommand) {
+			c.Options = append(c.Options, &discordgo.ApplicationCommandOption{
+				Name: "reset", Description: "Reset your desk", Type: discordgo.ApplicationCommandOptionSubCommand,
+			})
+		},
+		"option removed": func(c *discordgo.ApplicationCommand) { c.Options = nil },
+		"option renamed": func(c *discordgo.ApplicationCommand) { c.Options[0].Name = "visibility" },
+		"option type": func(c *discordgo.ApplicationCommand) {
+			c.Options[0].Options[0].Type = discordgo.ApplicationCommandOptionInteger
+		},
+		"option required":     func(c *discordgo.ApplicationCommand) { c.Options[0].Options[0].Required = false },
+		"option autocomplete": func(c *discordgo.ApplicationCommand) { c.Options[0].Options[0].Autocomplete = true },
+		"choice added": func(c *discordgo.ApplicationCommand) {
+			opt := c.Options[0].Options[0]
+			opt.Choices = append(opt.Choices, &discordgo.ApplicationCommandOptionChoice{Name: "dnd", Value: "dnd"})
+		},
+		"choice removed": func(c *discordgo.ApplicationCommand) {
+			opt := c.Options[0].Options[0]
+			opt.Choices = opt.Choices[:1]
+		},
+		"choice renamed": func(c *discordgo.ApplicationCommand) { c.Options[0].Options[0].Choices[1].Name = "knock first" },
+		"choice value":   func(c *discordgo.ApplicationCommand) { c.Options[0].Options[0].Choices[1].Value = "ask" },
+		"choices reordered": func(c *discordgo.ApplicationCommand) {
+			opt := c.Options[0].Options[0]
+			opt.Choices[0], opt.Choices[1] = opt.Choices[1], opt.Choices[0]
+		},
+	}
+	for name, edit := range changes {
+		if commandSignature(testCommand(edit)) == base {
+			t.Errorf("%s: signature didn't change", name)
+		}
+	}
+
+	same := map[string]func(*discordgo.ApplicationCommand){
+		"ID":             func(c *discordgo.ApplicationCommand) { c.ID = "123" },
+		"application ID": func(c *discordgo.ApplicationCommand) { c.ApplicationID = "456" },
+		"version":        func(c *discordgo.ApplicationCommand) { c.Version = "789" },
+		"guild ID":       func(c *discordgo.ApplicationCommand) { c.GuildID = "g1" },
+	}
+	for name, edit := range same {
+		if commandSignature(testCommand(edit)) != base {
+			t.Errorf("%s: signature changed", name)
+		}
+	}
+}
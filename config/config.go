@@ -0,0 +1,355 @@
+// Package config loads the bot's settings from a YAML file and environment
+// variables.
+//
+// Settings are layered: built-in defaults, then the file, then DESKBOT_*
+// environment variables. The bot token can come from DESKBOT_TOKEN or a file
+// named by token_file or DESKBOT_TOKEN_FILE, so it never has to appear on the
+// command line.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbarber/deskbot/prbuddy"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting the bot reads at startup.
+type Config struct {
+	// Token is the Discord bot token.
+	Token string `yaml:"token"`
+	// TokenFile names a file holding the token, used when Token is empty.
+	TokenFile string `yaml:"token_file"`
+	// DevGuild registers commands in this guild only, for development.
+	DevGuild string `yaml:"dev_guild"`
+	// LogLevel is one of debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
+	// LogFormat is text or json.
+	LogFormat string `yaml:"log_format"`
+	// HTTPAddr is where /healthz and /metrics are served, e.g. ":9090".
+	// Empty turns the listener off.
+	HTTPAddr string `yaml:"http_addr"`
+	// MemberLimit is how many members are fetched per guild when syncing
+	// desks, at most 1000.
+	MemberLimit int `yaml:"member_limit"`
+
+	State    State    `yaml:"state"`
+	Channels Channels `yaml:"channels"`
+	Schedule Schedule `yaml:"schedule"`
+	Features Features `yaml:"features"`
+	Shutdown Shutdown `yaml:"shutdown"`
+}
+
+// State holds the paths of the JSON state files.
+type State struct {
+	PRBuddy      string `yaml:"prbuddy"`
+	Desks        string `yaml:"desks"`
+	DeskSessions string `yaml:"desk_sessions"`
+	Audit        string `yaml:"audit"`
+}
+
+// Channels holds the channel names the bot looks for in each guild.
+type Channels struct {
+	// DeskCategory is the category desks are created in.
+	DeskCategory string `yaml:"desk_category"`
+	// Pairings is the text channel pairings and reminders are posted to.
+	Pairings string `yaml:"pairings"`
+}
+
+// Schedule holds defaults for PR buddy's scheduled jobs. Guilds can
+// override both with /prbuddy schedule and /prbuddy timezone.
+type Schedule struct {
+	// Pairings is the cron spec pairings are generated on.
+	Pairings string `yaml:"pairings"`
+	// TimeZone is the IANA zone schedules run in, or "" for local time.
+	TimeZone string `yaml:"time_zone"`
+}
+
+// Features turns parts of the bot on and off.
+type Features struct {
+	Desks       bool `yaml:"desks"`
+	PRBuddy     bool `yaml:"prbuddy"`
+	PairingDMs  bool `yaml:"pairing_dms"`
+	IdleSweeper bool `yaml:"idle_sweeper"`
+}
+
+// What happens to desk visibility when the bot shuts down.
+const (
+	// ShutdownLeave leaves every desk as it is.
+	ShutdownLeave = "leave"
+	// ShutdownShow shows every desk, so members can still find each other
+	// while the bot is down.
+	ShutdownShow = "show"
+	// ShutdownHideEmpty hides desks nobody is in, so none are left
+	// advertising an empty room until the bot is back.
+	ShutdownHideEmpty = "hide_empty"
+)
+
+// Shutdown controls how the bot stops.
+type Shutdown struct {
+	// Desks is ShutdownLeave, ShutdownShow or ShutdownHideEmpty.
+	Desks string `yaml:"desks"`
+	// Timeout bounds how long shutdown waits for running jobs and handlers
+	// and for desk updates, e.g. "30s".
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() Config {
+	return Config{
+		LogLevel:    "info",
+		LogFormat:   "text",
+		MemberLimit: 1000,
+		State: State{
+			PRBuddy:      "./prbuddy.json",
+			Desks:        "./desks.json",
+			DeskSessions: "./desk_sessions.json",
+			Audit:        "./audit.json",
+		},
+		Channels: Channels{
+			DeskCategory: "desks",
+			Pairings:     "general",
+		},
+		Schedule: Schedule{
+			Pairings: prbuddy.DefaultPairingSpec,
+		},
+		Features: Features{
+			Desks:       true,
+			PRBuddy:     true,
+			PairingDMs:  true,
+			IdleSweeper: true,
+		},
+		Shutdown: Shutdown{
+			Desks:   ShutdownLeave,
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Load reads the YAML file at path over the defaults, applies environment
+// overrides from getenv, reads the token file if needed and validates the
+// result. A missing file is only an error when required is set, so the bot
+// can run on defaults and environment variables alone.
+func Load(path string, required bool, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !required:
+		case err != nil:
+			return cfg, fmt.Errorf("config: read %s: %w", path, err)
+		default:
+			if err := decode(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("config: %s: %w", path, err)
+			}
+		}
+	}
+
+	if err := applyEnv(&cfg, getenv); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Token == "" && cfg.TokenFile != "" {
+		data, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return cfg, fmt.Errorf("config: read token file: %w", err)
+		}
+		cfg.Token = strings.TrimSpace(string(data))
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks every setting and reports all problems at once, one per
+// line, naming the setting as it appears in the file.
+func (c Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Token == "" {
+		add("token: no bot token; set DESKBOT_TOKEN, DESKBOT_TOKEN_FILE or token_file")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log_level: %q is not one of debug, info, warn, error", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "text", "json":
+	default:
+		add("log_format: %q is not one of text, json", c.LogFormat)
+	}
+	if c.HTTPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.HTTPAddr); err != nil {
+			add("http_addr: %q is not host:port", c.HTTPAddr)
+		}
+	}
+	if c.MemberLimit < 1 || c.MemberLimit > 1000 {
+		add("member_limit: %d is outside 1-1000", c.MemberLimit)
+	}
+	for name, path := range map[string]string{
+		"state.prbuddy":       c.State.PRBuddy,
+		"state.desks":         c.State.Desks,
+		"state.desk_sessions": c.State.DeskSessions,
+		"state.audit":         c.State.Audit,
+	} {
+		if path == "" {
+			add("%s: must not be empty", name)
+		}
+	}
+	if c.Channels.DeskCategory == "" {
+		add("channels.desk_category: must not be empty")
+	}
+	if c.Channels.Pairings == "" {
+		add("channels.pairings: must not be empty")
+	}
+	if _, err := prbuddy.ParseSpec(c.Schedule.Pairings); err != nil {
+		add("schedule.pairings: %v", err)
+	}
+	if c.Schedule.TimeZone != "" {
+		if _, err := time.LoadLocation(c.Schedule.TimeZone); err != nil {
+			add("schedule.time_zone: unknown time zone %q", c.Schedule.TimeZone)
+		}
+	}
+
+	switch c.Shutdown.Desks {
+	case ShutdownLeave, ShutdownShow, ShutdownHideEmpty:
+	default:
+		add("shutdown.desks: %q is not one of %s, %s, %s", c.Shutdown.Desks, ShutdownLeave, ShutdownShow, ShutdownHideEmpty)
+	}
+	if c.Shutdown.Timeout <= 0 {
+		add("shutdown.timeout: must be positive")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.Sort(problems)
+	return fmt.Errorf("config: invalid settings:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// Location returns the default schedule time zone.
+func (c Config) Location() *time.Location {
+	if c.Schedule.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Schedule.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// decode parses YAML strictly, so a misspelt setting is an error rather than
+// silently ignored.
+func decode(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// envVar maps an environment variable onto a setting.
+type envVar struct {
+	name  string
+	apply func(c *Config, value string) error
+}
+
+func str(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func integer(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func duration(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 30s", v)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func boolean(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// envVars lists every environment override.
+var envVars = []envVar{
+	{"DESKBOT_TOKEN", str(func(c *Config) *string { return &c.Token })},
+	{"DESKBOT_TOKEN_FILE", str(func(c *Config) *string { return &c.TokenFile })},
+	{"DESKBOT_DEV_GUILD", str(func(c *Config) *string { return &c.DevGuild })},
+	{"DESKBOT_LOG_LEVEL", str(func(c *Config) *string { return &c.LogLevel })},
+	{"DESKBOT_LOG_FORMAT", str(func(c *Config) *string { return &c.LogFormat })},
+	{"DESKBOT_HTTP_ADDR", str(func(c *Config) *string { return &c.HTTPAddr })},
+	{"DESKBOT_MEMBER_LIMIT", integer(func(c *Config) *int { return &c.MemberLimit })},
+	{"DESKBOT_STATE_PRBUDDY", str(func(c *Config) *string { return &c.State.PRBuddy })},
+	{"DESKBOT_STATE_DESKS", str(func(c *Config) *string { return &c.State.Desks })},
+	{"DESKBOT_STATE_DESK_SESSIONS", str(func(c *Config) *string { return &c.State.DeskSessions })},
+	{"DESKBOT_STATE_AUDIT", str(func(c *Config) *string { return &c.State.Audit })},
+	{"DESKBOT_CHANNELS_DESK_CATEGORY", str(func(c *Config) *string { return &c.Channels.DeskCategory })},
+	{"DESKBOT_CHANNELS_PAIRINGS", str(func(c *Config) *string { return &c.Channels.Pairings })},
+	{"DESKBOT_SCHEDULE_PAIRINGS", str(func(c *Config) *string { return &c.Schedule.Pairings })},
+	{"DESKBOT_SCHEDULE_TIME_ZONE", str(func(c *Config) *string { return &c.Schedule.TimeZone })},
+	{"DESKBOT_FEATURES_DESKS", boolean(func(c *Config) *bool { return &c.Features.Desks })},
+	{"DESKBOT_FEATURES_PRBUDDY", boolean(func(c *Config) *bool { return &c.Features.PRBuddy })},
+	{"DESKBOT_FEATURES_PAIRING_DMS", boolean(func(c *Config) *bool { return &c.Features.PairingDMs })},
+	{"DESKBOT_FEATURES_IDLE_SWEEPER", boolean(func(c *Config) *bool { return &c.Features.IdleSweeper })},
+	{"DESKBOT_SHUTDOWN_DESKS", str(func(c *Config) *string { return &c.Shutdown.Desks })},
+	{"DESKBOT_SHUTDOWN_TIMEOUT", duration(func(c *Config) *time.Duration { return &c.Shutdown.Timeout })},
+}
+
+// applyEnv overrides settings from any DESKBOT_* variables that are set.
+func applyEnv(c *Config, getenv func(string) string) error {
+	var problems []string
+	for _, v := range envVars {
+		value := getenv(v.name)
+		if value == "" {
+			continue
+		}
+		if err := v.apply(c, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", v.name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("config: invalid environment:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
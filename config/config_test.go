@@ -0,0 +1,206 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv backed by vars.
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+// writeFile writes contents to a file in a temp directory and returns its path.
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// --- Load -------------------------------------------------------------------
+
+func TestLoad_DefaultsWhenFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deskbot.yaml")
+	cfg, err := Load(path, false, env(map[string]string{"DESKBOT_TOKEN": "tok"}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := Default()
+	want.Token = "tok"
+	if cfg != want {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoad_RequiredFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deskbot.yaml")
+	if _, err := Load(path, true, env(nil)); err == nil {
+		t.Error("expected error for a missing required file")
+	}
+}
+
+func TestLoad_File(t *testing.T) {
+	path := writeFile(t, "deskbot.yaml", `
+token: from-file
+log_level: debug
+http_addr: ":9090"
+state:
+  prbuddy: /var/lib/deskbot/prbuddy.json
+channels:
+  pairings: eng-pairs
+schedule:
+  pairings: "0 10 * * 2"
+  time_zone: UTC
+features:
+  pairing_dms: false
+shutdown:
+  desks: hide_empty
+  timeout: 45s
+`)
+	cfg, err := Load(path, true, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Token != "from-file" || cfg.LogLevel != "debug" {
+		t.Errorf("token/log level: got %q/%q", cfg.Token, cfg.LogLevel)
+	}
+	if cfg.HTTPAddr != ":9090" {
+		t.Errorf("http_addr: got %q", cfg.HTTPAddr)
+	}
+	if cfg.State.PRBuddy != "/var/lib/deskbot/prbuddy.json" {
+		t.Errorf("state.prbuddy: got %q", cfg.State.PRBuddy)
+	}
+	if cfg.State.Desks != Default().State.Desks {
+		t.Errorf("unset state.desks should keep its default, got %q", cfg.State.Desks)
+	}
+	if cfg.Channels.Pairings != "eng-pairs" || cfg.Channels.DeskCategory != "desks" {
+		t.Errorf("channels: got %+v", cfg.Channels)
+	}
+	if cfg.Schedule.Pairings != "0 10 * * 2" || cfg.Location().String() != "UTC" {
+		t.Errorf("schedule: got %+v", cfg.Schedule)
+	}
+	if cfg.Features.PairingDMs || !cfg.Features.Desks {
+		t.Errorf("features: got %+v", cfg.Features)
+	}
+	if cfg.Shutdown.Desks != ShutdownHideEmpty || cfg.Shutdown.Timeout != 45*time.Second {
+		t.Errorf("shutdown: got %+v", cfg.Shutdown)
+	}
+}
+
+func TestLoad_UnknownKey(t *testing.T) {
+	path := writeFile(t, "deskbot.yaml", "token: x\nlog_levle: debug\n")
+	_, err := Load(path, true, env(nil))
+	if err == nil || !strings.Contains(err.Error(), "log_levle") {
+		t.Errorf("expected error naming the misspelt key, got %v", err)
+	}
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "deskbot.yaml", "token: from-file\nlog_level: debug\n")
+	cfg, err := Load(path, true, env(map[string]string{
+		"DESKBOT_TOKEN":                 "from-env",
+		"DESKBOT_LOG_LEVEL":             "warn",
+		"DESKBOT_LOG_FORMAT":            "json",
+		"DESKBOT_MEMBER_LIMIT":          "200",
+		"DESKBOT_FEATURES_IDLE_SWEEPER": "false",
+		"DESKBOT_SHUTDOWN_TIMEOUT":      "1m",
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Token != "from-env" || cfg.LogLevel != "warn" || cfg.MemberLimit != 200 {
+		t.Errorf("got token %q, log level %q, member limit %d", cfg.Token, cfg.LogLevel, cfg.MemberLimit)
+	}
+	if cfg.LogFormat != "json" {
+		t.Errorf("log format: got %q", cfg.LogFormat)
+	}
+	if cfg.Features.IdleSweeper {
+		t.Error("expected the idle sweeper to be turned off")
+	}
+	if cfg.Shutdown.Timeout != time.Minute {
+		t.Errorf("shutdown timeout: got %v", cfg.Shutdown.Timeout)
+	}
+}
+
+func TestLoad_BadEnv(t *testing.T) {
+	_, err := Load("", false, env(map[string]string{
+		"DESKBOT_TOKEN":            "tok",
+		"DESKBOT_MEMBER_LIMIT":     "lots",
+		"DESKBOT_FEATURES_DESKS":   "maybe",
+		"DESKBOT_SHUTDOWN_TIMEOUT": "soon",
+	}))
+	if err == nil {
+		t.Fatal("expected error for bad environment values")
+	}
+	for _, want := range []string{"DESKBOT_MEMBER_LIMIT", "DESKBOT_FEATURES_DESKS", "DESKBOT_SHUTDOWN_TIMEOUT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoad_TokenFile(t *testing.T) {
+	tokenPath := writeFile(t, "token", "secret\n")
+	cfg, err := Load("", false, env(map[string]string{"DESKBOT_TOKEN_FILE": tokenPath}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Token != "secret" {
+		t.Errorf("token: got %q", cfg.Token)
+	}
+}
+
+func TestLoad_TokenBeatsTokenFile(t *testing.T) {
+	cfg, err := Load("", false, env(map[string]string{
+		"DESKBOT_TOKEN":      "direct",
+		"DESKBOT_TOKEN_FILE": filepath.Join(t.TempDir(), "missing"),
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Token != "direct" {
+		t.Errorf("token: got %q", cfg.Token)
+	}
+}
+
+// --- Validate ---------------------------------------------------------------
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.LogLevel = "loud"
+	cfg.LogFormat = "xml"
+	cfg.MemberLimit = 5000
+	cfg.HTTPAddr = "9090"
+	cfg.State.Desks = ""
+	cfg.Schedule.Pairings = "mondays"
+	cfg.Schedule.TimeZone = "Mars/Olympus_Mons"
+	cfg.Shutdown.Desks = "vanish"
+	cfg.Shutdown.Timeout = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"token:", "log_level:", "log_format:", "http_addr:", "member_limit:", "state.desks:",
+		"schedule.pairings:", "schedule.time_zone:", "shutdown.desks:", "shutdown.timeout:",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_Defaults(t *testing.T) {
+	cfg := Default()
+	cfg.Token = "tok"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("defaults with a token should be valid: %v", err)
+	}
+}
@@ -0,0 +1,1061 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/desks"
+)
+
+// ---------------------------------------------------------------------------
+// /desk slash command
+// ---------------------------------------------------------------------------
+
+// zeroFloat and minBitrateKbps are addressable so they can be used as an
+// option's MinValue.
+var (
+	zeroFloat      = 0.0
+	minBitrateKbps = 8.0
+)
+
+// deskCommand is the full /desk command definition registered with Discord.
+var deskCommand = &discordgo.ApplicationCommand{
+	Name:        "desk",
+	Description: "Manage your desk voice channel",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Name:        "optout",
+			Description: "Archive your desk and stop the bot creating one",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user",
+					Description: "Admins only: opt out another member",
+					Type:        discordgo.ApplicationCommandOptionUser,
+				},
+			},
+		},
+		{
+			Name:        "optin",
+			Description: "Get your desk back after opting out",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user",
+					Description: "Admins only: opt in another member",
+					Type:        discordgo.ApplicationCommandOptionUser,
+				},
+			},
+		},
+		{
+			Name:        "mode",
+			Description: "Choose who can see and join your desk while you're in it",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "mode",
+					Description: "open: anyone can join, dnd: stay hidden, knock: visible but ask first",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "open", Value: string(desks.ModeOpen)},
+						{Name: "do not disturb", Value: string(desks.ModeDND)},
+						{Name: "knock", Value: string(desks.ModeKnock)},
+					},
+				},
+			},
+		},
+		{
+			Name:        "invite",
+			Description: "Let someone see and join your desk, even while it's hidden",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user",
+					Description: "The member to invite",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "kick",
+			Description: "Remove someone from your desk's guest list",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user",
+					Description: "The member to remove",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "notes",
+			Description: "Post session notes in your desk's text chat",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "state",
+					Description: "Turn session notes on or off",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "on", Value: "on"},
+						{Name: "off", Value: "off"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "rename",
+			Description: "Rename your desk",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "name",
+					Description: "The new desk name",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					MaxLength:   100,
+				},
+			},
+		},
+		{
+			Name:        "limit",
+			Description: "Limit how many people can join your desk",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "users",
+					Description: "Maximum number of people (0 for no limit)",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					MinValue:    &zeroFloat,
+					MaxValue:    99,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "bitrate",
+			Description: "Set your desk's audio bitrate",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "kbps",
+					Description: "Bitrate in kbps (8-96, higher on boosted servers)",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					MinValue:    &minBitrateKbps,
+					MaxValue:    384,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "lock",
+			Description: "Toggle whether your desk keeps its current visibility",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+		{
+			Name:        "reset",
+			Description: "Restore your desk's permissions",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+		{
+			Name:        "stats",
+			Description: "Show how long you've spent at desks this week",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "weeks_ago",
+					Description: "Look at an earlier week (1 = last week)",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					MinValue:    &zeroFloat,
+				},
+			},
+		},
+		{
+			Name:        "summary",
+			Description: "Admins only: everyone's desk time this week",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "weeks_ago",
+					Description: "Look at an earlier week (1 = last week)",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					MinValue:    &zeroFloat,
+				},
+			},
+		},
+		{
+			Name:        "roles",
+			Description: "Admins only: limit desks to members with certain roles",
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "add",
+					Description: "Give desks to members with this role",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "role",
+							Description: "The role to add",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "Stop giving desks to members with this role",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "role",
+							Description: "The role to remove",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "list",
+					Description: "Show which roles get desks",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+			},
+		},
+		{
+			Name:        "idle",
+			Description: "Admins only: clear out members left alone and muted in a desk",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "minutes",
+					Description: "How long before acting (0 turns this off)",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					MinValue:    &zeroFloat,
+					Required:    true,
+				},
+				{
+					Name:        "action",
+					Description: "What to do with idle members (default: move to AFK)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "move to the AFK channel", Value: string(desks.IdleMoveToAFK)},
+						{Name: "disconnect", Value: string(desks.IdleDisconnect)},
+					},
+				},
+			},
+		},
+		{
+			Name:        "board",
+			Description: "Admins only: manage the pinned desk status board",
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "set",
+					Description: "Post and pin the desk status board in a channel",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "channel",
+							Description:  "The channel to post the board in",
+							Type:         discordgo.ApplicationCommandOptionChannel,
+							ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+							Required:     true,
+						},
+					},
+				},
+				{
+					Name:        "off",
+					Description: "Unpin the desk status board and stop updating it",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+			},
+		},
+	},
+}
+
+func handleDesk(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	opts := i.ApplicationCommandData().Options
+	if len(opts) == 0 {
+		respond(s, i, "Unknown subcommand.")
+		return
+	}
+
+	switch opts[0].Name {
+	case "optout":
+		handleDeskOptOut(s, i, opts[0].Options)
+	case "optin":
+		handleDeskOptIn(s, i, opts[0].Options)
+	case "mode":
+		handleDeskMode(s, i, opts[0].Options)
+	case "invite":
+		handleDeskInvite(s, i, opts[0].Options)
+	case "kick":
+		handleDeskKick(s, i, opts[0].Options)
+	case "notes":
+		handleDeskNotes(s, i, opts[0].Options)
+	case "rename":
+		handleDeskRename(s, i, opts[0].Options)
+	case "limit":
+		handleDeskLimit(s, i, opts[0].Options)
+	case "bitrate":
+		handleDeskBitrate(s, i, opts[0].Options)
+	case "lock":
+		handleDeskLock(s, i)
+	case "reset":
+		handleDeskReset(s, i)
+	case "stats":
+		handleDeskStats(s, i, opts[0].Options)
+	case "summary":
+		handleDeskSummary(s, i, opts[0].Options)
+	case "roles":
+		handleDeskRoles(s, i, opts[0].Options)
+	case "idle":
+		handleDeskIdle(s, i, opts[0].Options)
+	case "board":
+		handleDeskBoard(s, i, opts[0].Options)
+	default:
+		respond(s, i, "Unknown subcommand.")
+	}
+}
+
+func handleDeskOptOut(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	member, err := deskTarget(s, i, opts)
+	if err != nil {
+		respond(s, i, err.Error())
+		return
+	}
+
+	if err := deskStore.SetOptedOut(i.GuildID, member.User.ID, true); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to opt out: %v", err))
+		return
+	}
+
+	deskChannel, err := memberDeskChannel(s, i.GuildID, member.User.ID)
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Opted out, but failed to find the desk: %v", err))
+		return
+	}
+	if deskChannel != nil && !isDeskArchived(i.GuildID, deskChannel.ID) {
+		if err := archiveDeskChannel(s, deskChannel, member.User.ID); err != nil {
+			respond(s, i, fmt.Sprintf("Opted out, but failed to archive the desk: %v", err))
+			return
+		}
+	}
+	respond(s, i, fmt.Sprintf("**%s** has opted out of desks. Their desk is archived until they opt back in.", member.DisplayName()))
+	auditInteraction(s, i, "Desk opted out", userMention(member.User.ID), "", "")
+}
+
+func handleDeskOptIn(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	member, err := deskTarget(s, i, opts)
+	if err != nil {
+		respond(s, i, err.Error())
+		return
+	}
+
+	if err := deskStore.SetOptedOut(i.GuildID, member.User.ID, false); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to opt in: %v", err))
+		return
+	}
+	if !deskStore.Eligible(i.GuildID, member.User.ID, member.Roles) {
+		respond(s, i, fmt.Sprintf("**%s** has opted in, but doesn't have a role that gets a desk.", member.DisplayName()))
+		return
+	}
+
+	maybeDeskCategoryId, ok := guildToDeskCategory.Load(i.GuildID)
+	if !ok {
+		respond(s, i, "This server has no DESKS category.")
+		return
+	}
+	guild, err := s.Guild(i.GuildID)
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Failed to find guild: %v", err))
+		return
+	}
+
+	queueMemberDeskSync(s, guild, maybeDeskCategoryId.(string), member)
+	respond(s, i, fmt.Sprintf("**%s** has opted back in to desks.", member.DisplayName()))
+	auditInteraction(s, i, "Desk opted in", userMention(member.User.ID), "", "")
+}
+
+func handleDeskMode(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	mode, err := desks.ParseMode(opts[0].StringValue())
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Invalid mode: %v", err))
+		return
+	}
+
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+
+	var before desks.Mode
+	if desk, ok := deskStore.DeskByChannel(i.GuildID, deskChannel.ID); ok {
+		before = cmp.Or(desk.Mode, desks.ModeOpen)
+	}
+	if err := deskStore.SetMode(i.GuildID, deskChannel.ID, mode); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set desk mode: %v", err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Your desk is now in **%s** mode.", mode))
+	auditInteraction(s, i, "Desk mode set", channelMention(deskChannel.ID), string(before), string(mode))
+
+	// Apply the new mode straight away if the desk is in use.
+	if deskOccupied(i.GuildID, deskChannel.ID) {
+		guild, err := s.Guild(i.GuildID)
+		if err != nil {
+			interactionLogger(i).Error("Failed to find guild", "err", err)
+			return
+		}
+		showDeskChannel(s, guild, deskChannel)
+	}
+}
+
+func handleDeskInvite(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	guest := opts[0].UserValue(s)
+	if guest.ID == i.Member.User.ID {
+		respond(s, i, "You can already get into your own desk.")
+		return
+	}
+
+	if err := inviteDeskGuest(s, deskChannel, guest.ID); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to invite %s: %v", guest.Username, err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("**%s** can now see and join your desk.", guest.Username))
+	auditInteraction(s, i, "Desk guest invited", channelMention(deskChannel.ID), "", userMention(guest.ID))
+}
+
+func handleDeskKick(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	guest := opts[0].UserValue(s)
+	if guest.ID == i.Member.User.ID {
+		respond(s, i, "You can't kick yourself from your own desk.")
+		return
+	}
+
+	if err := deskStore.RemoveGuest(i.GuildID, deskChannel.ID, guest.ID); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to kick %s: %v", guest.Username, err))
+		return
+	}
+	if err := setDeskGuestAccess(s, deskChannel, guest.ID, false); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to kick %s: %v", guest.Username, err))
+		return
+	}
+
+	// Disconnect them if they are sitting in the desk right now.
+	if vs, err := s.State.VoiceState(i.GuildID, guest.ID); err == nil && vs.ChannelID == deskChannel.ID {
+		if err := s.GuildMemberMove(i.GuildID, guest.ID, nil); err != nil {
+			interactionLogger(i).Error("Failed to disconnect kicked guest", "guest", guest.ID, "err", err)
+		}
+	}
+	respond(s, i, fmt.Sprintf("**%s** is no longer a guest at your desk.", guest.Username))
+	auditInteraction(s, i, "Desk guest kicked", channelMention(deskChannel.ID), userMention(guest.ID), "")
+}
+
+func handleDeskNotes(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	on := opts[0].StringValue() == "on"
+	before := notesEnabled(i.GuildID, deskChannel.ID)
+
+	if err := deskStore.SetNotes(i.GuildID, deskChannel.ID, on); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to change session notes: %v", err))
+		return
+	}
+	if !on {
+		discardNoteSession(deskChannel.ID)
+	}
+	if err := resetDeskPermissions(s, deskChannel, i.Member.User.ID); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to update desk permissions: %v", err))
+		return
+	}
+
+	// resetDeskPermissions hides the desk when it changes anything, so put an
+	// occupied desk back on show with the new history permissions.
+	refreshDeskVisibility(s, i.GuildID, deskChannel.ID)
+
+	auditInteraction(s, i, "Desk session notes set", channelMention(deskChannel.ID), onOff(before), onOff(on))
+
+	if on {
+		respond(s, i, "Session notes are on. I'll post who joins and a summary in your desk's text chat.")
+	} else {
+		respond(s, i, "Session notes are off.")
+	}
+}
+
+func handleDeskRename(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(opts[0].StringValue())
+	if name == "" {
+		respond(s, i, "Desk names can't be blank.")
+		return
+	}
+
+	err := runDeskEdit(i.GuildID, "name:"+deskChannel.ID, func() error {
+		_, err := editChannel(s, deskChannel.ID, &discordgo.ChannelEdit{Name: name})
+		return err
+	})
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Failed to rename your desk: %v", err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Your desk is now called **%s**.", name))
+	auditInteraction(s, i, "Desk renamed", channelMention(deskChannel.ID), deskChannel.Name, name)
+}
+
+func handleDeskLimit(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	limit := int(opts[0].IntValue())
+
+	err := runDeskEdit(i.GuildID, "limit:"+deskChannel.ID, func() error {
+		return setChannelUserLimit(s, deskChannel.ID, limit)
+	})
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set your desk's user limit: %v", err))
+		return
+	}
+	if limit == 0 {
+		respond(s, i, "Your desk no longer has a user limit.")
+	} else {
+		respond(s, i, fmt.Sprintf("Your desk now fits %d people.", limit))
+	}
+	auditInteraction(s, i, "Desk user limit set", channelMention(deskChannel.ID),
+		formatUserLimit(deskChannel.UserLimit), formatUserLimit(limit))
+}
+
+// formatUserLimit describes a desk's user limit for the audit log.
+func formatUserLimit(limit int) string {
+	if limit == 0 {
+		return "none"
+	}
+	return strconv.Itoa(limit)
+}
+
+func handleDeskBitrate(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	kbps := int(opts[0].IntValue())
+
+	err := runDeskEdit(i.GuildID, "bitrate:"+deskChannel.ID, func() error {
+		_, err := editChannel(s, deskChannel.ID, &discordgo.ChannelEdit{Bitrate: kbps * 1000})
+		return err
+	})
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set your desk's bitrate: %v", err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Your desk's bitrate is now %dkbps.", kbps))
+	auditInteraction(s, i, "Desk bitrate set", channelMention(deskChannel.ID),
+		fmt.Sprintf("%dkbps", deskChannel.Bitrate/1000), fmt.Sprintf("%dkbps", kbps))
+}
+
+func handleDeskLock(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+	locked := !deskLocked(i.GuildID, deskChannel.ID)
+
+	if err := deskStore.SetLocked(i.GuildID, deskChannel.ID, locked); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to change your desk's lock: %v", err))
+		return
+	}
+	if locked {
+		respond(s, i, "Your desk's visibility is locked. It won't be shown or hidden as people come and go.")
+		auditInteraction(s, i, "Desk visibility locked", channelMention(deskChannel.ID), "", "")
+		return
+	}
+	respond(s, i, "Your desk's visibility is unlocked.")
+	auditInteraction(s, i, "Desk visibility unlocked", channelMention(deskChannel.ID), "", "")
+	refreshDeskVisibility(s, i.GuildID, deskChannel.ID)
+}
+
+func handleDeskReset(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	deskChannel, ok := callerDeskChannel(s, i)
+	if !ok {
+		return
+	}
+
+	if err := resetDeskPermissions(s, deskChannel, i.Member.User.ID); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to reset your desk's permissions: %v", err))
+		return
+	}
+	respond(s, i, "Your desk's permissions have been reset.")
+	auditInteraction(s, i, "Desk permissions reset", channelMention(deskChannel.ID), "", "")
+	refreshDeskVisibility(s, i.GuildID, deskChannel.ID)
+}
+
+func handleDeskStats(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	if i.Member == nil {
+		respond(s, i, "Desk commands only work inside a server.")
+		return
+	}
+	now := time.Now()
+	weekStart := statsWeek(now, weeksAgoOption(opts))
+	usage := deskUsage(i.GuildID, weekStart, now)
+	respond(s, i, formatDeskStats(i.Member.User.ID, weekStart, usage[i.Member.User.ID]))
+}
+
+func handleDeskSummary(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	if !isAdmin(i) {
+		respond(s, i, "Only server managers can see everyone's desk time.")
+		return
+	}
+	now := time.Now()
+	weekStart := statsWeek(now, weeksAgoOption(opts))
+	respond(s, i, formatDeskSummary(weekStart, deskUsage(i.GuildID, weekStart, now)))
+}
+
+func handleDeskRoles(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	if len(opts) == 0 {
+		respond(s, i, "Unknown roles subcommand.")
+		return
+	}
+	if !isAdmin(i) {
+		respond(s, i, "Only server managers can change which roles get desks.")
+		return
+	}
+
+	switch opts[0].Name {
+	case "add":
+		role := opts[0].Options[0].RoleValue(s, i.GuildID)
+		if err := deskStore.AddDeskRole(i.GuildID, role.ID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to add role: %v", err))
+			return
+		}
+		respond(s, i, fmt.Sprintf("Members with <@&%s> now get desks.", role.ID))
+		auditInteraction(s, i, "Desk role added", fmt.Sprintf("<@&%s>", role.ID), "", "")
+		go syncGuildDesks(s, i.GuildID)
+
+	case "remove":
+		role := opts[0].Options[0].RoleValue(s, i.GuildID)
+		if err := deskStore.RemoveDeskRole(i.GuildID, role.ID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to remove role: %v", err))
+			return
+		}
+		respond(s, i, fmt.Sprintf("Members with <@&%s> no longer get desks from that role.", role.ID))
+		auditInteraction(s, i, "Desk role removed", fmt.Sprintf("<@&%s>", role.ID), "", "")
+		go syncGuildDesks(s, i.GuildID)
+
+	case "list":
+		roles := deskStore.DeskRoles(i.GuildID)
+		if len(roles) == 0 {
+			respond(s, i, "Every member gets a desk.")
+			return
+		}
+		mentions := make([]string, len(roles))
+		for idx, id := range roles {
+			mentions[idx] = fmt.Sprintf("<@&%s>", id)
+		}
+		respond(s, i, "Desks are given to members with: "+strings.Join(mentions, ", "))
+
+	default:
+		respond(s, i, "Unknown roles subcommand.")
+	}
+}
+
+func handleDeskIdle(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	if !isAdmin(i) {
+		respond(s, i, "Only server managers can change the idle policy.")
+		return
+	}
+
+	var minutes int64
+	action := desks.IdleMoveToAFK
+	for _, opt := range opts {
+		switch opt.Name {
+		case "minutes":
+			minutes = opt.IntValue()
+		case "action":
+			parsed, err := desks.ParseIdleAction(opt.StringValue())
+			if err != nil {
+				respond(s, i, fmt.Sprintf("Invalid action: %v", err))
+				return
+			}
+			action = parsed
+		}
+	}
+
+	before := formatIdlePolicy(deskStore.IdlePolicy(i.GuildID))
+	if err := deskStore.SetIdlePolicy(i.GuildID, int(minutes), action); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set idle policy: %v", err))
+		return
+	}
+	if minutes == 0 {
+		respond(s, i, "Idle members will be left alone.")
+	} else {
+		verb := "moved to the AFK channel"
+		if action == desks.IdleDisconnect {
+			verb = "disconnected"
+		}
+		respond(s, i, fmt.Sprintf("Members alone and muted or deafened in a desk for %d minutes will be %s.", minutes, verb))
+	}
+	auditInteraction(s, i, "Desk idle policy set", "", before, formatIdlePolicy(deskStore.IdlePolicy(i.GuildID)))
+}
+
+// formatIdlePolicy describes an idle policy for the audit log.
+func formatIdlePolicy(after time.Duration, action desks.IdleAction) string {
+	if after == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%s after %d minutes", action, int(after.Minutes()))
+}
+
+func handleDeskBoard(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	if len(opts) == 0 {
+		respond(s, i, "Unknown board subcommand.")
+		return
+	}
+	if !isAdmin(i) {
+		respond(s, i, "Only server managers can manage the desk status board.")
+		return
+	}
+
+	beforeChannelID, _ := deskStore.StatusBoard(i.GuildID)
+	switch opts[0].Name {
+	case "set":
+		channel := opts[0].Options[0].ChannelValue(s)
+		if err := setupStatusBoard(s, i.GuildID, channel.ID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to set up the status board: %v", err))
+			return
+		}
+		respond(s, i, fmt.Sprintf("The desk status board is pinned in <#%s>.", channel.ID))
+		auditInteraction(s, i, "Desk status board set", "", channelMention(beforeChannelID), channelMention(channel.ID))
+
+	case "off":
+		if err := removeStatusBoard(s, i.GuildID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to turn off the status board: %v", err))
+			return
+		}
+		respond(s, i, "The desk status board is off.")
+		auditInteraction(s, i, "Desk status board turned off", "", channelMention(beforeChannelID), "none")
+
+	default:
+		respond(s, i, "Unknown board subcommand.")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Knock buttons
+// ---------------------------------------------------------------------------
+
+// deskComponentPrefix namespaces the custom IDs of desk message components.
+// The rest of the ID is "<action>:<channel ID>[:<user ID>]".
+const deskComponentPrefix = "desk:"
+
+// postKnockPrompt posts a Knock button for a knock-mode desk, replacing the
+// desk's previous one. It goes in the desk's text chat unless session notes
+// hide the chat's history from visitors, in which case it goes in the
+// server's system channel.
+func postKnockPrompt(s *discordgo.Session, guildID string, channel *discordgo.Channel) {
+	desk, ok := deskStore.DeskByChannel(guildID, channel.ID)
+	if !ok {
+		return
+	}
+	removeKnockPrompt(s, guildID, desk)
+
+	promptChannelID := channel.ID
+	if desk.Notes {
+		guild, err := s.State.Guild(guildID)
+		if err != nil || guild.SystemChannelID == "" {
+			logger.Warn("No channel visitors can read for the knock prompt", "guild", guildID, "channel", channel.ID)
+			return
+		}
+		promptChannelID = guild.SystemChannelID
+	}
+
+	msg, err := s.ChannelMessageSendComplex(promptChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<@%s> would like you to knock before joining %s.", desk.OwnerID, channelMention(channel.ID)),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Knock",
+						Style:    discordgo.PrimaryButton,
+						CustomID: deskComponentPrefix + "knock:" + channel.ID,
+					},
+				},
+			},
+		},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		logger.Error("Failed to post knock prompt", "guild", guildID, "channel", promptChannelID, "err", err)
+		return
+	}
+	if err := deskStore.SetKnockPrompt(guildID, channel.ID, promptChannelID, msg.ID); err != nil {
+		logger.Error("Failed to record knock prompt", "guild", guildID, "channel", channel.ID, "err", err)
+	}
+}
+
+// removeKnockPrompt deletes a desk's Knock button message, if it has one.
+func removeKnockPrompt(s *discordgo.Session, guildID string, desk desks.Desk) {
+	if desk.KnockPromptID == "" {
+		return
+	}
+	if err := s.ChannelMessageDelete(desk.KnockPromptChannelID, desk.KnockPromptID); err != nil {
+		// Most likely deleted by hand already.
+		logger.Debug("Failed to delete knock prompt", "guild", guildID, "channel", desk.KnockPromptChannelID, "message", desk.KnockPromptID, "err", err)
+	}
+	if err := deskStore.SetKnockPrompt(guildID, desk.ChannelID, "", ""); err != nil {
+		logger.Error("Failed to forget knock prompt", "guild", guildID, "channel", desk.ChannelID, "err", err)
+	}
+}
+
+// letVisitorIn gives a member who knocked access to a desk until they leave
+// it. Guests already have access and are left alone.
+func letVisitorIn(s *discordgo.Session, channel *discordgo.Channel, userID string) error {
+	desk, ok := deskStore.DeskByChannel(channel.GuildID, channel.ID)
+	if !ok {
+		return fmt.Errorf("channel %s is not a desk", channel.ID)
+	}
+	if slices.Contains(desk.Guests, userID) {
+		return nil
+	}
+	if err := deskStore.AddVisitor(channel.GuildID, channel.ID, userID); err != nil {
+		return err
+	}
+	return setDeskGuestAccess(s, channel, userID, true)
+}
+
+// showVisitorsOut takes away the access letVisitorIn gave the given members,
+// or every visitor if none are given, unless they have since been invited
+// as guests.
+func showVisitorsOut(s *discordgo.Session, guildID string, channel *discordgo.Channel, userIDs ...string) {
+	desk, ok := deskStore.DeskByChannel(guildID, channel.ID)
+	if !ok {
+		return
+	}
+	for _, userID := range desk.Visitors {
+		if len(userIDs) > 0 && !slices.Contains(userIDs, userID) {
+			continue
+		}
+		if !slices.Contains(desk.Guests, userID) {
+			queueDeskEdit(guildID, guestEditKey(channel, userID), func() error {
+				return applyDeskGuestAccess(s, currentChannel(s, channel), userID, false)
+			})
+		}
+		if err := deskStore.RemoveVisitor(guildID, channel.ID, userID); err != nil {
+			logger.Error("Failed to forget visitor", "guild", guildID, "channel", channel.ID, "user", userID, "err", err)
+		}
+	}
+}
+
+func handleDeskComponent(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	if i.Member == nil {
+		return
+	}
+	parts := strings.Split(customID, ":")
+	if len(parts) < 2 {
+		respond(s, i, "Unknown desk button.")
+		return
+	}
+	action, channelID := parts[0], parts[1]
+
+	desk, ok := deskStore.DeskByChannel(i.GuildID, channelID)
+	if !ok {
+		respond(s, i, "That desk no longer exists.")
+		return
+	}
+
+	switch action {
+	case "knock":
+		if i.Member.User.ID == desk.OwnerID {
+			respond(s, i, "This is your desk, no need to knock.")
+			return
+		}
+		_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content: fmt.Sprintf("<@%s>, <@%s> is knocking.", desk.OwnerID, i.Member.User.ID),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Let them in",
+							Style:    discordgo.SuccessButton,
+							CustomID: deskComponentPrefix + "letin:" + channelID + ":" + i.Member.User.ID,
+						},
+					},
+				},
+			},
+			AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{desk.OwnerID}},
+		})
+		if err != nil {
+			respond(s, i, fmt.Sprintf("Failed to knock: %v", err))
+			return
+		}
+		respond(s, i, fmt.Sprintf("You knocked on <@%s>'s desk.", desk.OwnerID))
+
+	case "letin":
+		if len(parts) < 3 {
+			respond(s, i, "Unknown desk button.")
+			return
+		}
+		if i.Member.User.ID != desk.OwnerID {
+			respond(s, i, "Only the desk owner can let people in.")
+			return
+		}
+		guestID := parts[2]
+		channel, err := s.Channel(channelID)
+		if err != nil {
+			respond(s, i, fmt.Sprintf("Failed to find desk: %v", err))
+			return
+		}
+		if err := letVisitorIn(s, channel, guestID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to let them in: %v", err))
+			return
+		}
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:         fmt.Sprintf("<@%s> let <@%s> in until they leave.", desk.OwnerID, guestID),
+				Components:      []discordgo.MessageComponent{},
+				AllowedMentions: &discordgo.MessageAllowedMentions{},
+			},
+		})
+		if err != nil {
+			interactionLogger(i).Error("Failed to respond to interaction", "err", err)
+		}
+
+	default:
+		respond(s, i, "Unknown desk button.")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /desk helpers
+// ---------------------------------------------------------------------------
+
+// deskTarget returns the member a /desk subcommand acts on: the optional user
+// option if given, otherwise the caller. Acting on someone else is reserved
+// for server managers.
+func deskTarget(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.Member, error) {
+	if i.Member == nil {
+		return nil, fmt.Errorf("Desk commands only work inside a server.")
+	}
+	for _, opt := range opts {
+		if opt.Name != "user" {
+			continue
+		}
+		user := opt.UserValue(s)
+		if user.ID == i.Member.User.ID {
+			break
+		}
+		if !isAdmin(i) {
+			return nil, fmt.Errorf("Only server managers can change another member's desk.")
+		}
+		member, err := s.GuildMember(i.GuildID, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to find member: %v", err)
+		}
+		return member, nil
+	}
+	return i.Member, nil
+}
+
+// callerDeskChannel returns the invoking member's desk. If they have none it
+// responds to the interaction itself and returns false.
+func callerDeskChannel(s *discordgo.Session, i *discordgo.InteractionCreate) (*discordgo.Channel, bool) {
+	if i.Member == nil {
+		respond(s, i, "Desk commands only work inside a server.")
+		return nil, false
+	}
+	deskChannel, err := memberDeskChannel(s, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Failed to find your desk: %v", err))
+		return nil, false
+	}
+	if deskChannel == nil {
+		respond(s, i, "You don't have a desk.")
+		return nil, false
+	}
+	if isDeskArchived(i.GuildID, deskChannel.ID) {
+		respond(s, i, "Your desk is archived. Use `/desk optin` to get it back first.")
+		return nil, false
+	}
+	return deskChannel, true
+}
+
+// refreshDeskVisibility re-fetches a desk and shows or hides it to match
+// whether anyone is in it, as after a change to its settings.
+func refreshDeskVisibility(s *discordgo.Session, guildID, channelID string) {
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		logger.Error("Failed to find guild", "guild", guildID, "err", err)
+		return
+	}
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		logger.Error("Failed to find channel", "guild", guildID, "channel", channelID, "err", err)
+		return
+	}
+	if deskOccupied(guildID, channelID) {
+		showDeskChannel(s, guild, channel)
+	} else {
+		hideDeskChannel(s, guild, channel)
+	}
+}
+
+// inviteDeskGuest adds a member to a desk's guest list and grants them access.
+func inviteDeskGuest(s *discordgo.Session, channel *discordgo.Channel, guestID string) error {
+	if err := deskStore.AddGuest(channel.GuildID, channel.ID, guestID); err != nil {
+		return err
+	}
+	return setDeskGuestAccess(s, channel, guestID, true)
+}
+
+// memberDeskChannel looks up a member's desk in a guild, returning nil if
+// they have none.
+func memberDeskChannel(s *discordgo.Session, guildID, userID string) (*discordgo.Channel, error) {
+	maybeDeskCategoryId, ok := guildToDeskCategory.Load(guildID)
+	if !ok {
+		return nil, nil
+	}
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return nil, err
+	}
+	return findUserDeskChannel(guildID, channels, maybeDeskCategoryId, userID, s.State.User.ID), nil
+}
+
+// weeksAgoOption returns the weeks_ago option, defaulting to the current week.
+func weeksAgoOption(opts []*discordgo.ApplicationCommandInteractionDataOption) int64 {
+	for _, opt := range opts {
+		if opt.Name == "weeks_ago" {
+			return opt.IntValue()
+		}
+	}
+	return 0
+}
+
+// isAdmin reports whether the invoking member can manage the server.
+func isAdmin(i *discordgo.InteractionCreate) bool {
+	return i.Member != nil && i.Member.Permissions&(discordgo.PermissionManageServer|discordgo.PermissionAdministrator) != 0
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/editqueue"
+)
+
+// ---------------------------------------------------------------------------
+// Desk edit queue
+// ---------------------------------------------------------------------------
+
+// deskEdits runs desk channel edits one guild at a time. Startup and bursts of
+// voice events can ask for hundreds of edits at once; queueing them keeps a
+// single request in flight per guild, collapses repeated show/hide edits of
+// the same desk into the latest one and retries rate limits and server errors.
+var deskEdits = editqueue.New(editqueue.Options{
+	Retryable: retryableDiscordError,
+	OnRetry: func(guildID, key string, err error, after time.Duration) {
+		deskEditRetries.Inc()
+		logger.Warn("Retrying desk edit", "guild", guildID, "edit", key, "after", after, "err", err)
+	},
+	OnError: func(guildID, key string, err error) {
+		logger.Error("Desk edit failed", "guild", guildID, "edit", key, "err", err)
+	},
+	OnCoalesce: func(guildID, key string) {
+		deskEditsCoalesced.Inc()
+	},
+})
+
+// queueDeskEdit queues an edit for the guild. Edits with the same key replace
+// each other while they wait.
+func queueDeskEdit(guildID, key string, task editqueue.Task) {
+	if err := deskEdits.Enqueue(guildID, key, task); err != nil {
+		logger.Warn("Dropped desk edit", "guild", guildID, "edit", key, "err", err)
+	}
+}
+
+// deskEditWait bounds how long a command waits for its desk edit, leaving
+// time to answer the interaction within Discord's three seconds.
+const deskEditWait = 2 * time.Second
+
+// runDeskEdit queues an edit for the guild and waits for it, so commands can
+// report failures. Edits with the same key replace each other while they
+// wait; a replaced edit reports no error, as the newer one's caller hears how
+// it went. If the guild's queue is too busy to get to the edit in time, the
+// edit is left queued, its failure logged by the queue, and nil is returned.
+// It must not be called from a queued edit, which would wait on itself.
+func runDeskEdit(guildID, key string, task editqueue.Task) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deskEditWait)
+	defer cancel()
+
+	err := deskEdits.Do(ctx, guildID, key, task)
+	switch {
+	case errors.Is(err, editqueue.ErrSuperseded):
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		logger.Info("Desk edit still queued", "guild", guildID, "edit", key, "pending", deskEdits.Pending(guildID))
+		return nil
+	}
+	return err
+}
+
+// noRetry marks an error from a request that isn't safe to repeat, such as
+// creating a channel: if the request timed out after Discord acted on it, a
+// retry would create a second one.
+type noRetry struct {
+	err error
+}
+
+func (e noRetry) Error() string { return e.err.Error() }
+func (e noRetry) Unwrap() error { return e.err }
+
+// retryableDiscordError reports whether a failed Discord request is worth
+// retrying, and how long Discord asked us to wait if it said.
+func retryableDiscordError(err error) (bool, time.Duration) {
+	if errors.As(err, &noRetry{}) {
+		return false, 0
+	}
+
+	var rateLimited *discordgo.RateLimitError
+	if errors.As(err, &rateLimited) {
+		return true, rateLimited.RetryAfter
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		status := restErr.Response.StatusCode
+		if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+			return true, retryAfter(restErr.Response.Header)
+		}
+		return false, 0
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr), 0
+}
+
+// retryAfter reads the Retry-After header, which Discord sends in seconds,
+// returning 0 when it is missing or malformed.
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.ParseFloat(header.Get("Retry-After"), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// currentChannel returns the latest copy of a channel the bot knows about.
+// Queued edits use it so they build on edits that ran after they were queued.
+func currentChannel(s *discordgo.Session, channel *discordgo.Channel) *discordgo.Channel {
+	if latest, err := s.State.Channel(channel.ID); err == nil {
+		return latest
+	}
+	return channel
+}
+
+// guildChannels returns the guild's channels from the session state, falling
+// back to the API when the guild isn't cached.
+func guildChannels(s *discordgo.Session, guildID string) ([]*discordgo.Channel, error) {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return s.GuildChannels(guildID)
+	}
+	s.State.RLock()
+	defer s.State.RUnlock()
+	return append([]*discordgo.Channel(nil), guild.Channels...), nil
+}
+
+// editChannel edits a channel and updates the session state straight away,
+// rather than when the gateway's update arrives, so edits queued behind it
+// build on its result instead of undoing it.
+func editChannel(s *discordgo.Session, channelID string, data *discordgo.ChannelEdit) (*discordgo.Channel, error) {
+	channel, err := s.ChannelEdit(channelID, data)
+	if err != nil {
+		return nil, err
+	}
+	s.State.ChannelAdd(channel)
+	return channel, nil
+}
+
+// setChannelUserLimit sets a voice channel's user limit, 0 meaning none, and
+// updates the session state like editChannel. ChannelEdit omits a zero
+// UserLimit, so removing the limit needs a raw request.
+func setChannelUserLimit(s *discordgo.Session, channelID string, limit int) error {
+	endpoint := discordgo.EndpointChannel(channelID)
+	body, err := s.RequestWithBucketID("PATCH", endpoint, map[string]int{"user_limit": limit}, endpoint)
+	if err != nil {
+		return err
+	}
+	var channel discordgo.Channel
+	if err := json.Unmarshal(body, &channel); err != nil {
+		return fmt.Errorf("decode channel: %w", err)
+	}
+	s.State.ChannelAdd(&channel)
+	return nil
+}
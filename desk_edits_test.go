@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func restError(status int, retryAfter string) error {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set("Retry-After", retryAfter)
+	}
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: status, Header: header}}
+}
+
+func TestRetryableDiscordError(t *testing.T) {
+	rateLimited := &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+		TooManyRequests: &discordgo.TooManyRequests{RetryAfter: 1500 * time.Millisecond},
+	}}
+
+	cases := []struct {
+		name  string
+		err   error
+		retry bool
+		after time.Duration
+	}{
+		{"rate limit error", rateLimited, true, 1500 * time.Millisecond},
+		{"429 with Retry-After", restError(http.StatusTooManyRequests, "2.5"), true, 2500 * time.Millisecond},
+		{"429 without Retry-After", restError(http.StatusTooManyRequests, ""), true, 0},
+		{"server error", restError(http.StatusBadGateway, ""), true, 0},
+		{"wrapped server error", fmt.Errorf("edit: %w", restError(http.StatusServiceUnavailable, "1")), true, time.Second},
+		{"missing permissions", restError(http.StatusForbidden, ""), false, 0},
+		{"unknown channel", restError(http.StatusNotFound, ""), false, 0},
+		{"REST error without a response", &discordgo.RESTError{}, false, 0},
+		{"network error", &net.DNSError{Err: "timeout", IsTimeout: true}, true, 0},
+		{"other error", errors.New("boom"), false, 0},
+		{"not safe to repeat", noRetry{fmt.Errorf("create: %w", restError(http.StatusInternalServerError, ""))}, false, 0},
+		{"wrapped not safe to repeat", fmt.Errorf("sync: %w", noRetry{&net.DNSError{IsTimeout: true}}), false, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, after := retryableDiscordError(tc.err)
+			if retry != tc.retry || after != tc.after {
+				t.Errorf("got (%v, %v), want (%v, %v)", retry, after, tc.retry, tc.after)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      0,
+		"3":     3 * time.Second,
+		"0.25":  250 * time.Millisecond,
+		"0":     0,
+		"-1":    0,
+		"soon":  0,
+		"1e400": 0,
+	}
+	for value, want := range cases {
+		header := http.Header{}
+		if value != "" {
+			header.Set("Retry-After", value)
+		}
+		if got := retryAfter(header); got != want {
+			t.Errorf("Retry-After %q: got %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/desks"
+)
+
+// ---------------------------------------------------------------------------
+// Idle desk sweeper
+// ---------------------------------------------------------------------------
+
+// idleSweepInterval is how often desks are checked for idle members.
+const idleSweepInterval = time.Minute
+
+var idleTracker = desks.NewIdleTracker()
+
+// startIdleSweeper checks desks for idle members every idleSweepInterval
+// until the returned stop function is called. stop waits for a sweep in
+// progress to finish.
+func startIdleSweeper(s *discordgo.Session) (stop func()) {
+	stopCh, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(idleSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case now := <-ticker.C:
+				sweepIdleDesks(s, now)
+			}
+		}
+	}()
+	return func() {
+		close(stopCh)
+		<-done
+	}
+}
+
+// sweepIdleDesks moves or disconnects members who have sat alone and
+// self-muted or deafened in a desk for longer than their guild allows. Guilds
+// without an AFK channel fall back to disconnecting. The resulting voice state
+// update hides the emptied desk via hideDeskChannel as usual.
+func sweepIdleDesks(s *discordgo.Session, now time.Time) {
+	s.State.RLock()
+	guilds := make([]*discordgo.Guild, len(s.State.Guilds))
+	copy(guilds, s.State.Guilds)
+	s.State.RUnlock()
+
+	for _, guild := range guilds {
+		timeout, action := deskStore.IdlePolicy(guild.ID)
+		if timeout == 0 {
+			continue
+		}
+		maybeDeskCategoryId, ok := guildToDeskCategory.Load(guild.ID)
+		if !ok {
+			continue
+		}
+		deskCategoryId := maybeDeskCategoryId.(string)
+
+		s.State.RLock()
+		voiceStates := make([]discordgo.VoiceState, 0, len(guild.VoiceStates))
+		for _, vs := range guild.VoiceStates {
+			voiceStates = append(voiceStates, *vs)
+		}
+		afkChannelID := guild.AfkChannelID
+		s.State.RUnlock()
+
+		occupants := make(map[string]int)
+		for _, vs := range voiceStates {
+			occupants[vs.ChannelID]++
+		}
+
+		seen := make(map[string]bool)
+		for _, vs := range voiceStates {
+			seen[vs.UserID] = true
+
+			idle := occupants[vs.ChannelID] == 1 && (vs.SelfMute || vs.SelfDeaf)
+			if idle {
+				channel, err := s.State.Channel(vs.ChannelID)
+				idle = err == nil && channel.ParentID == deskCategoryId
+			}
+			if idleTracker.Observe(guild.ID, vs.UserID, idle, now) < timeout || !idle {
+				continue
+			}
+
+			var target *string
+			if action == desks.IdleMoveToAFK && afkChannelID != "" {
+				target = &afkChannelID
+			}
+			logger.Info("Removing idle member from desk", "guild", guild.ID, "channel", vs.ChannelID, "user", vs.UserID, "action", action)
+			if err := s.GuildMemberMove(guild.ID, vs.UserID, target); err != nil {
+				logger.Error("Failed to move idle member", "guild", guild.ID, "channel", vs.ChannelID, "user", vs.UserID, "err", err)
+				continue
+			}
+			idleTracker.Observe(guild.ID, vs.UserID, false, now)
+		}
+		idleTracker.Retain(guild.ID, seen)
+	}
+}
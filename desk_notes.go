@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ---------------------------------------------------------------------------
+// Desk session notes
+// ---------------------------------------------------------------------------
+
+// noteSession is a desk's current occupied stretch, from the first member
+// joining until the last one leaves.
+type noteSession struct {
+	start        time.Time
+	participants []string
+}
+
+var (
+	noteSessionsMutex sync.Mutex
+	noteSessions      = make(map[string]*noteSession) // desk channel ID → session
+)
+
+// notesEnabled reports whether a desk's owner has turned on session notes.
+func notesEnabled(guildID, channelID string) bool {
+	desk, ok := deskStore.DeskByChannel(guildID, channelID)
+	return ok && desk.Notes
+}
+
+// noteDeskJoin posts to the desk's text chat when someone joins, opening a
+// new session if the desk was empty.
+func noteDeskJoin(s *discordgo.Session, guildID, channelID, userID string, at time.Time) {
+	if !notesEnabled(guildID, channelID) {
+		return
+	}
+
+	noteSessionsMutex.Lock()
+	session, ok := noteSessions[channelID]
+	if !ok {
+		session = &noteSession{start: at}
+		noteSessions[channelID] = session
+	}
+	if !slices.Contains(session.participants, userID) {
+		session.participants = append(session.participants, userID)
+	}
+	noteSessionsMutex.Unlock()
+
+	msg := fmt.Sprintf("<@%s> joined.", userID)
+	if !ok {
+		msg = fmt.Sprintf("📝 Session started <t:%d:t> — <@%s> joined.", at.Unix(), userID)
+	}
+	postDeskNote(s, channelID, msg)
+}
+
+// noteDeskEmpty closes the desk's session, if any, and posts a summary.
+func noteDeskEmpty(s *discordgo.Session, channelID string, at time.Time) {
+	noteSessionsMutex.Lock()
+	session, ok := noteSessions[channelID]
+	delete(noteSessions, channelID)
+	noteSessionsMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	mentions := make([]string, len(session.participants))
+	for idx, userID := range session.participants {
+		mentions[idx] = fmt.Sprintf("<@%s>", userID)
+	}
+	postDeskNote(s, channelID, fmt.Sprintf("📝 Session ended after %s with %s.",
+		formatDuration(at.Sub(session.start)), strings.Join(mentions, ", ")))
+}
+
+// discardNoteSession forgets a desk's open session without posting a summary,
+// e.g. after the owner turns notes off mid-session.
+func discardNoteSession(channelID string) {
+	noteSessionsMutex.Lock()
+	delete(noteSessions, channelID)
+	noteSessionsMutex.Unlock()
+}
+
+// postDeskNote sends a message to a desk's built-in text chat without
+// pinging anyone it mentions.
+func postDeskNote(s *discordgo.Session, channelID, msg string) {
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         msg,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		logger.Error("Failed to post desk note", "channel", channelID, "err", err)
+	}
+}
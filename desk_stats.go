@@ -0,0 +1,115 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/desks"
+)
+
+// ---------------------------------------------------------------------------
+// Desk time tracking
+// ---------------------------------------------------------------------------
+
+// recordDeskSession writes a seat the member just vacated to the session log.
+func recordDeskSession(s *discordgo.Session, guildID, userID string, channel *discordgo.Channel, seat desks.Seat, end time.Time) {
+	err := sessionLog.Record(guildID, desks.Session{
+		UserID:    userID,
+		ChannelID: channel.ID,
+		OwnerID:   deskOwner(guildID, channel.ID),
+		Start:     seat.Since,
+		End:       end,
+		Present:   seat.Company,
+	})
+	if err != nil {
+		logger.Error("Failed to record desk session", "guild", guildID, "channel", channel.ID, "err", err)
+	}
+}
+
+// deskAffinity feeds how many hours each pair of members spent together at
+// desks into PR buddy's stretch and comfort pairing modes.
+func deskAffinity(guildID string) map[[2]string]float64 {
+	now := time.Now()
+	together := desks.Together(sessionLog.Sessions(guildID, now.Add(-desks.SessionRetention), now))
+
+	out := make(map[[2]string]float64, len(together))
+	for pair, d := range together {
+		out[pair] = d.Hours()
+	}
+	return out
+}
+
+// deskUsage returns every member's time at desks for the week starting at
+// weekStart, counting sessions still in progress up to now.
+func deskUsage(guildID string, weekStart, now time.Time) map[string]desks.Usage {
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	sessions := sessionLog.Sessions(guildID, weekStart, weekEnd)
+
+	owners := make(map[string]string)
+	for _, desk := range deskStore.Desks(guildID) {
+		owners[desk.ChannelID] = desk.OwnerID
+	}
+	for userID, seat := range presence.Seats(guildID) {
+		sessions = append(sessions, desks.Session{
+			UserID:    userID,
+			ChannelID: seat.ChannelID,
+			OwnerID:   owners[seat.ChannelID],
+			Start:     seat.Since,
+			End:       now,
+		})
+	}
+	return desks.UsageBetween(sessions, weekStart, weekEnd)
+}
+
+// statsWeek returns the start of the week weeksAgo weeks before now.
+func statsWeek(now time.Time, weeksAgo int64) time.Time {
+	return desks.WeekStart(now).AddDate(0, 0, -7*int(weeksAgo))
+}
+
+// formatDeskStats renders a single member's weekly desk time.
+func formatDeskStats(userID string, weekStart time.Time, usage desks.Usage) string {
+	week := weekStart.Format("Jan 2, 2006")
+	if usage.Total() == 0 {
+		return fmt.Sprintf("<@%s> hasn't been at a desk in the week of %s.", userID, week)
+	}
+	return fmt.Sprintf("**Desk time for <@%s> — week of %s**\nTotal: %s\nAt their desk: %s\nVisiting others: %s",
+		userID, week,
+		formatDuration(usage.Total()), formatDuration(usage.Own), formatDuration(usage.Visiting))
+}
+
+// formatDeskSummary renders everyone's weekly desk time, most first.
+func formatDeskSummary(weekStart time.Time, usage map[string]desks.Usage) string {
+	week := weekStart.Format("Jan 2, 2006")
+	if len(usage) == 0 {
+		return fmt.Sprintf("Nobody was at a desk in the week of %s.", week)
+	}
+
+	userIDs := make([]string, 0, len(usage))
+	for userID := range usage {
+		userIDs = append(userIDs, userID)
+	}
+	slices.SortFunc(userIDs, func(a, b string) int {
+		if c := cmp.Compare(usage[b].Total(), usage[a].Total()); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Desk presence — week of %s**\n", week))
+	for idx, userID := range userIDs {
+		u := usage[userID]
+		sb.WriteString(fmt.Sprintf("%d. <@%s> — %s (%s visiting)\n", idx+1, userID, formatDuration(u.Total()), formatDuration(u.Visiting)))
+	}
+	return sb.String()
+}
+
+// formatDuration renders a duration as hours and minutes, e.g. "3h 05m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,514 @@
+// Package desks persists per-guild desk configuration, member preferences and
+// desk ownership for deskbot.
+//
+// The store is the source of truth for which voice channel belongs to which
+// member. All state is kept in a single JSON file, in the same way as the PR
+// buddy state, and survives bot restarts.
+package desks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Mode controls how an occupied desk is shown to the rest of the guild.
+type Mode string
+
+const (
+	// ModeOpen shows the desk to everyone while it is occupied.
+	ModeOpen Mode = "open"
+	// ModeDND keeps the desk hidden even while it is occupied.
+	ModeDND Mode = "dnd"
+	// ModeKnock shows the desk while it is occupied, but only lets people in
+	// once the owner answers their knock.
+	ModeKnock Mode = "knock"
+)
+
+// ParseMode converts a user-supplied string into a Mode.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeOpen, ModeDND, ModeKnock:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown desk mode %q", s)
+}
+
+// Desk is the persisted record of a member's desk voice channel.
+type Desk struct {
+	// ChannelID is the Discord voice channel ID (snowflake string).
+	ChannelID string `json:"channel_id"`
+	// OwnerID is the Discord user ID of the member the desk belongs to.
+	OwnerID string `json:"owner_id"`
+	// CreatedAt is when the desk channel was created.
+	CreatedAt time.Time `json:"created_at"`
+	// Archived is set while the owner is opted out or otherwise ineligible.
+	// The channel is kept so its settings survive until they opt back in.
+	Archived bool `json:"archived,omitempty"`
+	// Mode is the owner's chosen visibility mode. Empty means ModeOpen.
+	Mode Mode `json:"mode,omitempty"`
+	// Guests are user IDs the owner has invited. They can see and join the
+	// desk even while it is hidden from everyone else.
+	Guests []string `json:"guests,omitempty"`
+	// Notes turns on session notes in the desk's text chat: who joined when
+	// a session starts, and a summary when it ends.
+	Notes bool `json:"notes,omitempty"`
+	// Locked desks keep whatever visibility they have; the bot stops showing
+	// and hiding them as people come and go.
+	Locked bool `json:"locked,omitempty"`
+	// Visitors are members the owner let in after they knocked. Unlike
+	// guests, they only keep access until they leave.
+	Visitors []string `json:"visitors,omitempty"`
+	// KnockPromptChannelID and KnockPromptID locate the knock-mode desk's
+	// current Knock button message, so it can be replaced rather than piling
+	// up a new one each time the desk is shown.
+	KnockPromptChannelID string `json:"knock_prompt_channel_id,omitempty"`
+	KnockPromptID        string `json:"knock_prompt_id,omitempty"`
+}
+
+// clone returns a deep copy of d.
+func (d *Desk) clone() Desk {
+	cp := *d
+	cp.Guests = slices.Clone(d.Guests)
+	cp.Visitors = slices.Clone(d.Visitors)
+	return cp
+}
+
+// EffectiveMode returns the desk's mode, defaulting to ModeOpen.
+func (d Desk) EffectiveMode() Mode {
+	if d.Mode == "" {
+		return ModeOpen
+	}
+	return d.Mode
+}
+
+// store is the JSON-serialisable state for a single guild.
+type store struct {
+	// DeskRoles limits desks to members holding at least one of these role
+	// IDs. An empty list means every member gets a desk.
+	DeskRoles []string `json:"desk_roles,omitempty"`
+	// OptedOut is the set of user IDs who have asked not to have a desk.
+	OptedOut map[string]bool `json:"opted_out,omitempty"`
+	// Desks records every known desk channel in the guild.
+	Desks []*Desk `json:"desks,omitempty"`
+	// StatusChannelID and StatusMessageID locate the pinned desk status
+	// board, if one has been set up.
+	StatusChannelID string `json:"status_channel_id,omitempty"`
+	StatusMessageID string `json:"status_message_id,omitempty"`
+	// IdleMinutes is how long a member may sit alone and muted or deafened
+	// in a desk before IdleAction is taken. Zero turns the policy off.
+	IdleMinutes int        `json:"idle_minutes,omitempty"`
+	IdleAction  IdleAction `json:"idle_action,omitempty"`
+}
+
+// Store holds desk state for every guild. Construct one with New. It is safe
+// for concurrent use.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	guilds map[string]*store // guild ID → state
+}
+
+// New creates a Store that persists state to the given file path.
+func New(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		guilds: make(map[string]*store),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetOptedOut records whether a member has opted out of having a desk.
+func (s *Store) SetOptedOut(guildID, userID string, optedOut bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	if optedOut {
+		if g.OptedOut == nil {
+			g.OptedOut = make(map[string]bool)
+		}
+		g.OptedOut[userID] = true
+	} else {
+		delete(g.OptedOut, userID)
+	}
+	return s.save()
+}
+
+// OptedOut reports whether a member has opted out of having a desk.
+func (s *Store) OptedOut(guildID, userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.guild(guildID).OptedOut[userID]
+}
+
+// AddDeskRole adds a role to the set of roles whose members get desks.
+// Adding a role that is already configured is not an error.
+func (s *Store) AddDeskRole(guildID, roleID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	if slices.Contains(g.DeskRoles, roleID) {
+		return nil
+	}
+	g.DeskRoles = append(g.DeskRoles, roleID)
+	return s.save()
+}
+
+// RemoveDeskRole removes a role from the set of roles whose members get
+// desks. Once the last role is removed every member gets a desk again.
+func (s *Store) RemoveDeskRole(guildID, roleID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	g.DeskRoles = slices.DeleteFunc(g.DeskRoles, func(id string) bool { return id == roleID })
+	return s.save()
+}
+
+// DeskRoles returns a copy of the roles whose members get desks.
+func (s *Store) DeskRoles(guildID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.guild(guildID).DeskRoles)
+}
+
+// Eligible reports whether a member holding the given roles should have a
+// desk: they must not have opted out, and if desk roles are configured they
+// must hold at least one of them.
+func (s *Store) Eligible(guildID, userID string, roles []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	if g.OptedOut[userID] {
+		return false
+	}
+	if len(g.DeskRoles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if slices.Contains(g.DeskRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordDesk stores a desk channel as belonging to ownerID. Any existing
+// record for the same owner or the same channel is replaced, so a member only
+// ever owns one desk and a desk only ever has one owner.
+func (s *Store) RecordDesk(guildID, channelID, ownerID string, createdAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	g.Desks = slices.DeleteFunc(g.Desks, func(d *Desk) bool {
+		return d.OwnerID == ownerID || d.ChannelID == channelID
+	})
+	g.Desks = append(g.Desks, &Desk{
+		ChannelID: channelID,
+		OwnerID:   ownerID,
+		CreatedAt: createdAt.UTC(),
+	})
+	return s.save()
+}
+
+// RemoveDesk forgets a desk channel, e.g. after it was deleted.
+// It is not an error to remove a channel that is not a desk.
+func (s *Store) RemoveDesk(guildID, channelID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	g.Desks = slices.DeleteFunc(g.Desks, func(d *Desk) bool { return d.ChannelID == channelID })
+	return s.save()
+}
+
+// SetArchived records whether a desk is archived.
+func (s *Store) SetArchived(guildID, channelID string, archived bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	d.Archived = archived
+	return s.save()
+}
+
+// SetMode records a desk's visibility mode.
+func (s *Store) SetMode(guildID, channelID string, mode Mode) error {
+	if _, err := ParseMode(string(mode)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	d.Mode = mode
+	return s.save()
+}
+
+// SetNotes turns a desk's session notes on or off.
+func (s *Store) SetNotes(guildID, channelID string, on bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	d.Notes = on
+	return s.save()
+}
+
+// SetLocked locks or unlocks a desk's visibility.
+func (s *Store) SetLocked(guildID, channelID string, locked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	d.Locked = locked
+	return s.save()
+}
+
+// AddGuest adds a member to a desk's guest list. Adding an existing guest is
+// not an error.
+func (s *Store) AddGuest(guildID, channelID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	if slices.Contains(d.Guests, userID) {
+		return nil
+	}
+	d.Guests = append(d.Guests, userID)
+	return s.save()
+}
+
+// RemoveGuest removes a member from a desk's guest list. It is not an error
+// to remove someone who is not a guest.
+func (s *Store) RemoveGuest(guildID, channelID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	d.Guests = slices.DeleteFunc(d.Guests, func(id string) bool { return id == userID })
+	return s.save()
+}
+
+// AddVisitor records a member let in by a knock. Adding an existing visitor
+// is not an error.
+func (s *Store) AddVisitor(guildID, channelID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	if slices.Contains(d.Visitors, userID) {
+		return nil
+	}
+	d.Visitors = append(d.Visitors, userID)
+	return s.save()
+}
+
+// RemoveVisitor forgets a member let in by a knock. It is not an error to
+// remove someone who is not a visitor.
+func (s *Store) RemoveVisitor(guildID, channelID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	d.Visitors = slices.DeleteFunc(d.Visitors, func(id string) bool { return id == userID })
+	return s.save()
+}
+
+// SetKnockPrompt records where a desk's Knock button message is, or clears it
+// when messageID is "".
+func (s *Store) SetKnockPrompt(guildID, channelID, promptChannelID, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.deskByChannel(guildID, channelID)
+	if d == nil {
+		return fmt.Errorf("channel %s is not a desk", channelID)
+	}
+	if messageID == "" {
+		promptChannelID = ""
+	}
+	d.KnockPromptChannelID, d.KnockPromptID = promptChannelID, messageID
+	return s.save()
+}
+
+// DeskByOwner returns a copy of the desk owned by a member.
+func (s *Store) DeskByOwner(guildID, ownerID string) (Desk, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.guild(guildID).Desks {
+		if d.OwnerID == ownerID {
+			return d.clone(), true
+		}
+	}
+	return Desk{}, false
+}
+
+// DeskByChannel returns a copy of the desk record for a voice channel.
+func (s *Store) DeskByChannel(guildID, channelID string) (Desk, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d := s.deskByChannel(guildID, channelID); d != nil {
+		return d.clone(), true
+	}
+	return Desk{}, false
+}
+
+// Desks returns a copy of every desk record in the guild.
+func (s *Store) Desks(guildID string) []Desk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	out := make([]Desk, len(g.Desks))
+	for i, d := range g.Desks {
+		out[i] = d.clone()
+	}
+	return out
+}
+
+// SetStatusBoard records where the guild's desk status board message lives.
+// Pass empty IDs to turn the board off.
+func (s *Store) SetStatusBoard(guildID, channelID, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	g.StatusChannelID = channelID
+	g.StatusMessageID = messageID
+	return s.save()
+}
+
+// StatusBoard returns the channel and message ID of the guild's desk status
+// board. Both are empty if no board is configured.
+func (s *Store) StatusBoard(guildID string) (channelID, messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	return g.StatusChannelID, g.StatusMessageID
+}
+
+// SetIdlePolicy configures what happens to members left idle and alone in a
+// desk. A zero minutes value turns the policy off.
+func (s *Store) SetIdlePolicy(guildID string, minutes int, action IdleAction) error {
+	if minutes < 0 {
+		return fmt.Errorf("idle minutes must not be negative")
+	}
+	if _, err := ParseIdleAction(string(action)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	g.IdleMinutes = minutes
+	g.IdleAction = action
+	return s.save()
+}
+
+// IdlePolicy returns the guild's idle timeout and action. A zero timeout
+// means the policy is off.
+func (s *Store) IdlePolicy(guildID string) (time.Duration, IdleAction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.guild(guildID)
+	return time.Duration(g.IdleMinutes) * time.Minute, g.IdleAction
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// guild returns (creating if necessary) the store for a guild.
+// Caller must hold s.mu.
+func (s *Store) guild(guildID string) *store {
+	if g, ok := s.guilds[guildID]; ok {
+		return g
+	}
+	g := &store{}
+	s.guilds[guildID] = g
+	return g
+}
+
+// deskByChannel returns the live desk record for a channel, or nil.
+// Caller must hold s.mu.
+func (s *Store) deskByChannel(guildID, channelID string) *Desk {
+	for _, d := range s.guild(guildID).Desks {
+		if d.ChannelID == channelID {
+			return d
+		}
+	}
+	return nil
+}
+
+// load reads persisted state from disk. Missing file is treated as empty state.
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("desks: read %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, &s.guilds); err != nil {
+		return fmt.Errorf("desks: parse %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// save atomically writes current state to disk.
+// Caller must hold s.mu.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.guilds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("desks: marshal state: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("desks: write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("desks: rename to %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package desks
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestStore creates a Store backed by a file in a temp directory.
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "desks.json")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s, path
+}
+
+// --- Opt-out ----------------------------------------------------------------
+
+func TestSetOptedOut(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	if err := s.SetOptedOut("g1", "u1", true); err != nil {
+		t.Fatalf("SetOptedOut: %v", err)
+	}
+	if !s.OptedOut("g1", "u1") {
+		t.Error("expected u1 to be opted out")
+	}
+
+	if err := s.SetOptedOut("g1", "u1", false); err != nil {
+		t.Fatalf("SetOptedOut: %v", err)
+	}
+	if s.OptedOut("g1", "u1") {
+		t.Error("expected u1 to be opted back in")
+	}
+}
+
+func TestEligible_OptedOut(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	if !s.Eligible("g1", "u1", nil) {
+		t.Error("member should be eligible by default")
+	}
+	_ = s.SetOptedOut("g1", "u1", true)
+	if s.Eligible("g1", "u1", nil) {
+		t.Error("opted-out member should not be eligible")
+	}
+}
+
+// --- Desk roles -------------------------------------------------------------
+
+func TestEligible_DeskRoles(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.AddDeskRole("g1", "staff")
+
+	if s.Eligible("g1", "u1", []string{"guest"}) {
+		t.Error("member without a desk role should not be eligible")
+	}
+	if !s.Eligible("g1", "u1", []string{"guest", "staff"}) {
+		t.Error("member with a desk role should be eligible")
+	}
+
+	_ = s.SetOptedOut("g1", "u1", true)
+	if s.Eligible("g1", "u1", []string{"staff"}) {
+		t.Error("opt-out should win over a desk role")
+	}
+}
+
+func TestAddDeskRole_Idempotent(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.AddDeskRole("g1", "staff")
+	_ = s.AddDeskRole("g1", "staff")
+
+	if roles := s.DeskRoles("g1"); len(roles) != 1 {
+		t.Errorf("want 1 desk role, got %v", roles)
+	}
+}
+
+func TestRemoveDeskRole_LastRoleAllowsEveryone(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.AddDeskRole("g1", "staff")
+	if err := s.RemoveDeskRole("g1", "staff"); err != nil {
+		t.Fatalf("RemoveDeskRole: %v", err)
+	}
+	if !s.Eligible("g1", "u1", nil) {
+		t.Error("every member should be eligible once no desk roles remain")
+	}
+}
+
+// --- Desk records -----------------------------------------------------------
+
+func TestRecordDesk_Lookup(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	created := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+	if err := s.RecordDesk("g1", "c1", "u1", created); err != nil {
+		t.Fatalf("RecordDesk: %v", err)
+	}
+
+	byOwner, ok := s.DeskByOwner("g1", "u1")
+	if !ok || byOwner.ChannelID != "c1" {
+		t.Fatalf("DeskByOwner: got %+v, %v", byOwner, ok)
+	}
+	if !byOwner.CreatedAt.Equal(created) {
+		t.Errorf("CreatedAt: want %v, got %v", created, byOwner.CreatedAt)
+	}
+
+	byChannel, ok := s.DeskByChannel("g1", "c1")
+	if !ok || byChannel.OwnerID != "u1" {
+		t.Errorf("DeskByChannel: got %+v, %v", byChannel, ok)
+	}
+}
+
+func TestRecordDesk_ReplacesOwnerAndChannel(t *testing.T) {
+	s, _ := newTestStore(t)
+	now := time.Now()
+
+	_ = s.RecordDesk("g1", "c1", "u1", now)
+	_ = s.RecordDesk("g1", "c2", "u1", now) // u1 gets a new desk
+	_ = s.RecordDesk("g1", "c2", "u2", now) // c2 changes hands
+
+	if desks := s.Desks("g1"); len(desks) != 1 {
+		t.Fatalf("want 1 desk, got %+v", desks)
+	}
+	if _, ok := s.DeskByOwner("g1", "u1"); ok {
+		t.Error("u1 should no longer own a desk")
+	}
+	if d, _ := s.DeskByChannel("g1", "c2"); d.OwnerID != "u2" {
+		t.Errorf("c2 owner: want u2, got %q", d.OwnerID)
+	}
+}
+
+func TestRemoveDesk(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.RemoveDesk("g1", "c1"); err != nil {
+		t.Fatalf("RemoveDesk: %v", err)
+	}
+	if _, ok := s.DeskByChannel("g1", "c1"); ok {
+		t.Error("desk should be forgotten")
+	}
+	if err := s.RemoveDesk("g1", "nonexistent"); err != nil {
+		t.Errorf("expected no error removing unknown desk, got: %v", err)
+	}
+}
+
+func TestSetArchived(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.SetArchived("g1", "c1", true); err != nil {
+		t.Fatalf("SetArchived: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); !d.Archived {
+		t.Error("expected desk to be archived")
+	}
+	if err := s.SetArchived("g1", "nonexistent", true); err == nil {
+		t.Error("expected error archiving unknown desk")
+	}
+}
+
+// --- Modes ------------------------------------------------------------------
+
+func TestParseMode(t *testing.T) {
+	for _, in := range []string{"open", "dnd", "knock"} {
+		if m, err := ParseMode(in); err != nil || string(m) != in {
+			t.Errorf("ParseMode(%q) = %q, %v", in, m, err)
+		}
+	}
+	if _, err := ParseMode("busy"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if d, _ := s.DeskByChannel("g1", "c1"); d.EffectiveMode() != ModeOpen {
+		t.Errorf("default mode: want open, got %q", d.EffectiveMode())
+	}
+
+	if err := s.SetMode("g1", "c1", ModeKnock); err != nil {
+		t.Fatalf("SetMode: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); d.EffectiveMode() != ModeKnock {
+		t.Errorf("want knock, got %q", d.EffectiveMode())
+	}
+
+	if err := s.SetMode("g1", "c1", Mode("busy")); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	if err := s.SetMode("g1", "nonexistent", ModeDND); err == nil {
+		t.Error("expected error setting mode on unknown desk")
+	}
+}
+
+// --- Notes ------------------------------------------------------------------
+
+func TestSetNotes(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.SetNotes("g1", "c1", true); err != nil {
+		t.Fatalf("SetNotes: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); !d.Notes {
+		t.Error("expected notes to be on")
+	}
+	if err := s.SetNotes("g1", "nonexistent", true); err == nil {
+		t.Error("expected error turning on notes for unknown desk")
+	}
+}
+
+func TestSetLocked(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.SetLocked("g1", "c1", true); err != nil {
+		t.Fatalf("SetLocked: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); !d.Locked {
+		t.Error("expected desk to be locked")
+	}
+	if err := s.SetLocked("g1", "nonexistent", true); err == nil {
+		t.Error("expected error locking unknown desk")
+	}
+}
+
+// --- Guests -----------------------------------------------------------------
+
+func TestGuests(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.AddGuest("g1", "c1", "u2"); err != nil {
+		t.Fatalf("AddGuest: %v", err)
+	}
+	_ = s.AddGuest("g1", "c1", "u2")
+	_ = s.AddGuest("g1", "c1", "u3")
+
+	d, _ := s.DeskByChannel("g1", "c1")
+	if len(d.Guests) != 2 {
+		t.Fatalf("want 2 guests, got %v", d.Guests)
+	}
+
+	// The returned desk is a copy.
+	d.Guests[0] = "mutated"
+	if d2, _ := s.DeskByChannel("g1", "c1"); d2.Guests[0] != "u2" {
+		t.Error("DeskByChannel returned shared guest slice")
+	}
+
+	if err := s.RemoveGuest("g1", "c1", "u2"); err != nil {
+		t.Fatalf("RemoveGuest: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); len(d.Guests) != 1 || d.Guests[0] != "u3" {
+		t.Errorf("want guests [u3], got %v", d.Guests)
+	}
+
+	if err := s.AddGuest("g1", "nonexistent", "u2"); err == nil {
+		t.Error("expected error inviting to unknown desk")
+	}
+}
+
+func TestVisitors(t *testing.T) {
+	s, path := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.AddVisitor("g1", "c1", "u2"); err != nil {
+		t.Fatalf("AddVisitor: %v", err)
+	}
+	_ = s.AddVisitor("g1", "c1", "u2")
+
+	reloaded, err := New(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if d, _ := reloaded.DeskByChannel("g1", "c1"); len(d.Visitors) != 1 || d.Visitors[0] != "u2" || len(d.Guests) != 0 {
+		t.Errorf("want visitors [u2] and no guests, got %+v", d)
+	}
+
+	if err := s.RemoveVisitor("g1", "c1", "u2"); err != nil {
+		t.Fatalf("RemoveVisitor: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); len(d.Visitors) != 0 {
+		t.Errorf("want no visitors, got %v", d.Visitors)
+	}
+	if err := s.AddVisitor("g1", "nonexistent", "u2"); err == nil {
+		t.Error("expected error letting someone into an unknown desk")
+	}
+}
+
+func TestSetKnockPrompt(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.RecordDesk("g1", "c1", "u1", time.Now())
+	if err := s.SetKnockPrompt("g1", "c1", "general", "m1"); err != nil {
+		t.Fatalf("SetKnockPrompt: %v", err)
+	}
+	if d, _ := s.DeskByChannel("g1", "c1"); d.KnockPromptChannelID != "general" || d.KnockPromptID != "m1" {
+		t.Errorf("unexpected prompt: %+v", d)
+	}
+	_ = s.SetKnockPrompt("g1", "c1", "general", "")
+	if d, _ := s.DeskByChannel("g1", "c1"); d.KnockPromptChannelID != "" || d.KnockPromptID != "" {
+		t.Errorf("want the prompt cleared, got %+v", d)
+	}
+	if err := s.SetKnockPrompt("g1", "nonexistent", "c1", "m1"); err == nil {
+		t.Error("expected error for unknown desk")
+	}
+}
+
+// --- Idle policy ------------------------------------------------------------
+
+func TestSetIdlePolicy(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	if timeout, _ := s.IdlePolicy("g1"); timeout != 0 {
+		t.Errorf("idle policy should be off by default, got %v", timeout)
+	}
+	if err := s.SetIdlePolicy("g1", 30, IdleMoveToAFK); err != nil {
+		t.Fatalf("SetIdlePolicy: %v", err)
+	}
+	if timeout, action := s.IdlePolicy("g1"); timeout != 30*time.Minute || action != IdleMoveToAFK {
+		t.Errorf("got %v, %q", timeout, action)
+	}
+	if err := s.SetIdlePolicy("g1", -1, IdleDisconnect); err == nil {
+		t.Error("expected error for negative minutes")
+	}
+	if err := s.SetIdlePolicy("g1", 10, IdleAction("kick")); err == nil {
+		t.Error("expected error for unknown action")
+	}
+}
+
+// --- Persistence ------------------------------------------------------------
+
+func TestPersistence_RoundTrip(t *testing.T) {
+	s1, path := newTestStore(t)
+
+	_ = s1.SetOptedOut("g1", "u1", true)
+	_ = s1.AddDeskRole("g1", "staff")
+	_ = s1.RecordDesk("g1", "c1", "u1", time.Now())
+	_ = s1.SetStatusBoard("g1", "status", "m1")
+
+	s2, err := New(path)
+	if err != nil {
+		t.Fatalf("New (reload): %v", err)
+	}
+	if !s2.OptedOut("g1", "u1") {
+		t.Error("opt-out not persisted")
+	}
+	if roles := s2.DeskRoles("g1"); len(roles) != 1 || roles[0] != "staff" {
+		t.Errorf("desk roles not persisted: %v", roles)
+	}
+	if d, ok := s2.DeskByOwner("g1", "u1"); !ok || d.ChannelID != "c1" {
+		t.Errorf("desk not persisted: %+v", d)
+	}
+	if channelID, messageID := s2.StatusBoard("g1"); channelID != "status" || messageID != "m1" {
+		t.Errorf("status board not persisted: %q, %q", channelID, messageID)
+	}
+}
+
+func TestMultipleGuilds_Isolated(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_ = s.SetOptedOut("g1", "u1", true)
+	_ = s.AddDeskRole("g1", "staff")
+
+	if s.OptedOut("g2", "u1") {
+		t.Error("opt-out leaked into g2")
+	}
+	if !s.Eligible("g2", "u1", nil) {
+		t.Error("desk roles leaked into g2")
+	}
+}
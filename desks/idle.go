@@ -0,0 +1,75 @@
+package desks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IdleAction is what happens to a member left idle and alone in a desk.
+type IdleAction string
+
+const (
+	// IdleMoveToAFK moves the member to the guild's AFK channel.
+	IdleMoveToAFK IdleAction = "afk"
+	// IdleDisconnect disconnects the member from voice.
+	IdleDisconnect IdleAction = "disconnect"
+)
+
+// ParseIdleAction converts a user-supplied string into an IdleAction.
+func ParseIdleAction(s string) (IdleAction, error) {
+	switch a := IdleAction(s); a {
+	case IdleMoveToAFK, IdleDisconnect:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown idle action %q", s)
+}
+
+// IdleTracker remembers, in memory, how long each member has been idle in a
+// desk. It is fed by periodic sweeps and is safe for concurrent use.
+type IdleTracker struct {
+	mu     sync.Mutex
+	guilds map[string]map[string]time.Time // guild ID → user ID → idle since
+}
+
+// NewIdleTracker creates an empty IdleTracker.
+func NewIdleTracker() *IdleTracker {
+	return &IdleTracker{guilds: make(map[string]map[string]time.Time)}
+}
+
+// Observe records whether a member is idle at now and returns how long they
+// have been idle without interruption. A member who is not idle is reset.
+func (t *IdleTracker) Observe(guildID, userID string, idle bool, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !idle {
+		delete(t.guilds[guildID], userID)
+		return 0
+	}
+
+	since, ok := t.guilds[guildID]
+	if !ok {
+		since = make(map[string]time.Time)
+		t.guilds[guildID] = since
+	}
+	start, ok := since[userID]
+	if !ok {
+		since[userID] = now
+		return 0
+	}
+	return now.Sub(start)
+}
+
+// Retain forgets every member of the guild not in userIDs, e.g. members who
+// have left voice since the last sweep.
+func (t *IdleTracker) Retain(guildID string, userIDs map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for userID := range t.guilds[guildID] {
+		if !userIDs[userID] {
+			delete(t.guilds[guildID], userID)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package desks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIdleAction(t *testing.T) {
+	for _, in := range []string{"afk", "disconnect"} {
+		if a, err := ParseIdleAction(in); err != nil || string(a) != in {
+			t.Errorf("ParseIdleAction(%q) = %q, %v", in, a, err)
+		}
+	}
+	if _, err := ParseIdleAction("kick"); err == nil {
+		t.Error("expected error for unknown idle action")
+	}
+}
+
+func TestIdleTracker_Observe(t *testing.T) {
+	tr := NewIdleTracker()
+	start := time.Date(2026, 4, 6, 22, 0, 0, 0, time.UTC)
+
+	if got := tr.Observe("g1", "u1", true, start); got != 0 {
+		t.Errorf("first idle observation: want 0, got %v", got)
+	}
+	if got := tr.Observe("g1", "u1", true, start.Add(10*time.Minute)); got != 10*time.Minute {
+		t.Errorf("want 10m idle, got %v", got)
+	}
+
+	// Unmuting resets the clock.
+	tr.Observe("g1", "u1", false, start.Add(11*time.Minute))
+	tr.Observe("g1", "u1", true, start.Add(12*time.Minute))
+	if got := tr.Observe("g1", "u1", true, start.Add(15*time.Minute)); got != 3*time.Minute {
+		t.Errorf("after reset: want 3m idle, got %v", got)
+	}
+}
+
+func TestIdleTracker_Retain(t *testing.T) {
+	tr := NewIdleTracker()
+	start := time.Now()
+
+	tr.Observe("g1", "u1", true, start)
+	tr.Observe("g1", "u2", true, start)
+	tr.Retain("g1", map[string]bool{"u2": true})
+
+	if got := tr.Observe("g1", "u1", true, start.Add(time.Hour)); got != 0 {
+		t.Errorf("u1 should have been forgotten, got %v idle", got)
+	}
+	if got := tr.Observe("g1", "u2", true, start.Add(time.Hour)); got != time.Hour {
+		t.Errorf("u2 should have been kept, got %v idle", got)
+	}
+}
@@ -0,0 +1,174 @@
+package desks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Seat records which desk a member is sitting in and since when.
+type Seat struct {
+	ChannelID string
+	Since     time.Time
+	// Company lists everyone else who has been in the desk at the same time
+	// since the member sat down.
+	Company []string
+}
+
+// Presence tracks, in memory, which members are currently sitting in a desk.
+// It is rebuilt from voice states on startup and is safe for concurrent use.
+type Presence struct {
+	mu     sync.Mutex
+	guilds map[string]map[string]Seat // guild ID → user ID → seat
+}
+
+// NewPresence creates an empty Presence.
+func NewPresence() *Presence {
+	return &Presence{guilds: make(map[string]map[string]Seat)}
+}
+
+// Join records a member sitting down at a desk. Joining the desk they are
+// already in keeps their original start time.
+func (p *Presence) Join(guildID, userID, channelID string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seats, ok := p.guilds[guildID]
+	if !ok {
+		seats = make(map[string]Seat)
+		p.guilds[guildID] = seats
+	}
+	if seat, ok := seats[userID]; ok && seat.ChannelID == channelID {
+		return
+	}
+
+	seat := Seat{ChannelID: channelID, Since: at}
+	for otherID, other := range seats {
+		if otherID == userID || other.ChannelID != channelID {
+			continue
+		}
+		seat.Company = append(seat.Company, otherID)
+		if !slices.Contains(other.Company, userID) {
+			other.Company = append(other.Company, userID)
+			seats[otherID] = other
+		}
+	}
+	slices.Sort(seat.Company)
+	seats[userID] = seat
+}
+
+// Leave records a member leaving a desk and returns the seat they vacated.
+// It is a no-op returning false if they have already moved on to a
+// different desk.
+func (p *Presence) Leave(guildID, userID, channelID string) (Seat, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seat, ok := p.guilds[guildID][userID]
+	if !ok || seat.ChannelID != channelID {
+		return Seat{}, false
+	}
+	delete(p.guilds[guildID], userID)
+	return seat, true
+}
+
+// Seats returns a copy of every occupied seat in the guild, keyed by user ID.
+func (p *Presence) Seats(guildID string) map[string]Seat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make(map[string]Seat, len(p.guilds[guildID]))
+	for userID, seat := range p.guilds[guildID] {
+		seat.Company = slices.Clone(seat.Company)
+		out[userID] = seat
+	}
+	return out
+}
+
+// MaxStatusBoardLength is the most characters FormatStatusBoard returns,
+// Discord's limit for a message.
+const MaxStatusBoardLength = 2000
+
+// FormatStatusBoard renders the desk status board message. owners maps desk
+// channel IDs to their owner's user ID; members sitting in someone else's desk
+// are listed as visiting it. Durations use Discord relative timestamps so the
+// message doesn't need editing just to keep them current. Boards too long for
+// one message list the longest-seated members and end with how many more
+// there are.
+func FormatStatusBoard(seats map[string]Seat, owners map[string]string) string {
+	type line struct {
+		since time.Time
+		text  string
+	}
+	var atDesk, visiting []line
+	for userID, seat := range seats {
+		owner := owners[seat.ChannelID]
+		since := fmt.Sprintf("<t:%d:R>", seat.Since.Unix())
+		if owner == "" || owner == userID {
+			atDesk = append(atDesk, line{seat.Since, fmt.Sprintf("• <@%s> since %s", userID, since)})
+		} else {
+			visiting = append(visiting, line{seat.Since, fmt.Sprintf("• <@%s> at <@%s>'s desk since %s", userID, owner, since)})
+		}
+	}
+
+	// Longest-seated first; ties broken by text so output is stable.
+	byLongest := func(a, b line) int {
+		if c := a.since.Compare(b.since); c != 0 {
+			return c
+		}
+		return strings.Compare(a.text, b.text)
+	}
+	slices.SortFunc(atDesk, byLongest)
+	slices.SortFunc(visiting, byLongest)
+
+	if len(atDesk) == 0 && len(visiting) == 0 {
+		return "**Desk status**\nNobody is at a desk right now."
+	}
+
+	// Each entry is a line of the message; members are counted so a board cut
+	// short can say how many it left out.
+	type entry struct {
+		text   string
+		member bool
+	}
+	entries := []entry{{text: "**Desk status**"}}
+	for _, section := range []struct {
+		title string
+		lines []line
+	}{{"__At their desk__", atDesk}, {"__Visiting__", visiting}} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		entries = append(entries, entry{}, entry{text: section.title})
+		for _, l := range section.lines {
+			entries = append(entries, entry{text: l.text, member: true})
+		}
+	}
+
+	// Leave room for the "…and N more" line when cutting the board short.
+	const reserve = len("\n…and 10000 more")
+	var out []string
+	length := 0
+	for idx, e := range entries {
+		n := utf8.RuneCountInString(e.text) + 1
+		if length+n > MaxStatusBoardLength-reserve {
+			more := 0
+			for _, rest := range entries[idx:] {
+				if rest.member {
+					more++
+				}
+			}
+			for len(out) > 1 && !strings.HasPrefix(out[len(out)-1], "•") {
+				out = out[:len(out)-1] // drop a heading left without members
+			}
+			out = append(out, fmt.Sprintf("…and %d more", more))
+			break
+		}
+		out = append(out, e.text)
+		length += n
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,144 @@
+package desks
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// --- Presence ---------------------------------------------------------------
+
+func TestPresence_JoinLeave(t *testing.T) {
+	p := NewPresence()
+	start := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+
+	p.Join("g1", "u1", "c1", start)
+	p.Join("g1", "u1", "c1", start.Add(time.Hour)) // reconnect to the same desk
+
+	seats := p.Seats("g1")
+	if len(seats) != 1 || !seats["u1"].Since.Equal(start) {
+		t.Fatalf("unexpected seats: %+v", seats)
+	}
+
+	p.Leave("g1", "u1", "c1")
+	if len(p.Seats("g1")) != 0 {
+		t.Error("expected no seats after leaving")
+	}
+}
+
+func TestPresence_MoveBetweenDesks(t *testing.T) {
+	p := NewPresence()
+	start := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+	moved := start.Add(time.Hour)
+
+	p.Join("g1", "u2", "c1", start)
+	p.Join("g1", "u1", "c1", start)
+
+	// A move is handled as leaving the old desk, then joining the new one.
+	seat, ok := p.Leave("g1", "u1", "c1")
+	if !ok {
+		t.Fatal("expected the seat at c1 to be returned")
+	}
+	if seat.ChannelID != "c1" || !seat.Since.Equal(start) || len(seat.Company) != 1 || seat.Company[0] != "u2" {
+		t.Errorf("unexpected seat at c1: %+v", seat)
+	}
+	p.Join("g1", "u1", "c2", moved)
+
+	got := p.Seats("g1")["u1"]
+	if got.ChannelID != "c2" || !got.Since.Equal(moved) || len(got.Company) != 0 {
+		t.Errorf("want u1 alone at c2 since %v, got %+v", moved, got)
+	}
+}
+
+func TestPresence_LeaveAfterMovingOn(t *testing.T) {
+	p := NewPresence()
+	now := time.Now()
+
+	p.Join("g1", "u1", "c1", now)
+	p.Join("g1", "u1", "c2", now)
+	if _, ok := p.Leave("g1", "u1", "c1"); ok {
+		t.Error("leaving a desk the member already moved on from should be a no-op")
+	}
+	if seat := p.Seats("g1")["u1"]; seat.ChannelID != "c2" {
+		t.Errorf("want u1 at c2, got %+v", seat)
+	}
+}
+
+func TestPresence_Company(t *testing.T) {
+	p := NewPresence()
+	now := time.Now()
+
+	p.Join("g1", "u1", "c1", now)
+	p.Join("g1", "u2", "c1", now)
+	p.Join("g1", "u3", "c2", now)
+
+	seat, ok := p.Leave("g1", "u1", "c1")
+	if !ok {
+		t.Fatal("expected u1 to leave c1")
+	}
+	if len(seat.Company) != 1 || seat.Company[0] != "u2" {
+		t.Errorf("u1 company: want [u2], got %v", seat.Company)
+	}
+	if company := p.Seats("g1")["u2"].Company; len(company) != 1 || company[0] != "u1" {
+		t.Errorf("u2 company: want [u1], got %v", company)
+	}
+	if _, ok := p.Leave("g1", "u3", "c1"); ok {
+		t.Error("leaving a desk you're not in should be a no-op")
+	}
+}
+
+// --- FormatStatusBoard ------------------------------------------------------
+
+func TestFormatStatusBoard_Empty(t *testing.T) {
+	got := FormatStatusBoard(nil, nil)
+	if !strings.Contains(got, "Nobody is at a desk") {
+		t.Errorf("unexpected empty board: %q", got)
+	}
+}
+
+func TestFormatStatusBoard_AtDeskAndVisiting(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	seats := map[string]Seat{
+		"u1": {ChannelID: "c1", Since: start},
+		"u2": {ChannelID: "c1", Since: start.Add(time.Minute)},
+	}
+	owners := map[string]string{"c1": "u1"}
+
+	got := FormatStatusBoard(seats, owners)
+
+	if !strings.Contains(got, "__At their desk__\n• <@u1> since <t:1700000000:R>") {
+		t.Errorf("owner not listed at their desk:\n%s", got)
+	}
+	if !strings.Contains(got, "__Visiting__\n• <@u2> at <@u1>'s desk since <t:1700000060:R>") {
+		t.Errorf("visitor not listed:\n%s", got)
+	}
+}
+
+func TestFormatStatusBoard_CappedToOneMessage(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	seats := make(map[string]Seat)
+	owners := make(map[string]string)
+	for n := range 200 {
+		userID := fmt.Sprintf("1000000000000000%03d", n)
+		channelID := fmt.Sprintf("2000000000000000%03d", n)
+		seats[userID] = Seat{ChannelID: channelID, Since: start.Add(time.Duration(n) * time.Minute)}
+		owners[channelID] = userID
+	}
+
+	got := FormatStatusBoard(seats, owners)
+
+	if n := utf8.RuneCountInString(got); n > MaxStatusBoardLength {
+		t.Fatalf("board is %d characters, want at most %d", n, MaxStatusBoardLength)
+	}
+	listed := strings.Count(got, "\n• ")
+	want := fmt.Sprintf("\n…and %d more", 200-listed)
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("want the board to end with %q, got:\n%s", want, got[len(got)-100:])
+	}
+	// The longest-seated members are the ones shown.
+	if !strings.Contains(got, "<@1000000000000000000>") {
+		t.Error("longest-seated member missing")
+	}
+}
@@ -0,0 +1,303 @@
+package desks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SessionRetention is how long finished desk sessions are kept before they
+// are pruned from the log.
+const SessionRetention = 12 * 7 * 24 * time.Hour
+
+// Session is one member's stay at a desk, from sitting down to leaving.
+type Session struct {
+	// UserID is the member who sat at the desk.
+	UserID string `json:"user_id"`
+	// ChannelID is the desk's voice channel.
+	ChannelID string `json:"channel_id"`
+	// OwnerID is the desk's owner at the time. It equals UserID when the
+	// member was at their own desk.
+	OwnerID string    `json:"owner_id,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Present lists everyone else who was in the desk during the session.
+	Present []string `json:"present,omitempty"`
+}
+
+// Usage is how long a member spent at desks over some period.
+type Usage struct {
+	// Own is time spent at their own desk.
+	Own time.Duration
+	// Visiting is time spent at other people's desks.
+	Visiting time.Duration
+}
+
+// Total returns the member's combined time at desks.
+func (u Usage) Total() time.Duration {
+	return u.Own + u.Visiting
+}
+
+// SessionLog persists finished desk sessions per guild. Construct one with
+// NewSessionLog. It is safe for concurrent use.
+//
+// The file is a log with one JSON line per session, so recording a session
+// appends a line rather than rewriting every session kept. Pruned sessions
+// stay in the file until they make up half of it, when it is compacted.
+type SessionLog struct {
+	mu     sync.Mutex
+	path   string
+	guilds map[string][]Session // guild ID → sessions, oldest first
+	lines  int                  // sessions in the file, pruned ones included
+}
+
+// logLine is one line of the session log file.
+type logLine struct {
+	GuildID string `json:"guild_id"`
+	Session
+}
+
+// NewSessionLog creates a SessionLog that persists to the given file path.
+func NewSessionLog(path string) (*SessionLog, error) {
+	l := &SessionLog{
+		path:   path,
+		guilds: make(map[string][]Session),
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends a finished session to the guild's log and prunes sessions
+// that ended more than SessionRetention before it.
+func (l *SessionLog) Record(guildID string, session Session) error {
+	if !session.End.After(session.Start) {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := session.End.Add(-SessionRetention)
+	sessions := slices.DeleteFunc(l.guilds[guildID], func(s Session) bool {
+		return s.End.Before(cutoff)
+	})
+	l.guilds[guildID] = append(sessions, session)
+
+	if err := l.appendLine(guildID, session); err != nil {
+		return err
+	}
+	if l.lines > 2*l.count() {
+		return l.compact()
+	}
+	return nil
+}
+
+// Sessions returns a copy of the guild's sessions that overlap [from, to).
+func (l *SessionLog) Sessions(guildID string, from, to time.Time) []Session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []Session
+	for _, s := range l.guilds[guildID] {
+		if s.End.After(from) && s.Start.Before(to) {
+			s.Present = slices.Clone(s.Present)
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// UsageBetween sums each member's time at desks within [from, to). Sessions
+// straddling the window only count the part inside it.
+func UsageBetween(sessions []Session, from, to time.Time) map[string]Usage {
+	out := make(map[string]Usage)
+	for _, s := range sessions {
+		start, end := s.Start, s.End
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		u := out[s.UserID]
+		if s.OwnerID == "" || s.OwnerID == s.UserID {
+			u.Own += end.Sub(start)
+		} else {
+			u.Visiting += end.Sub(start)
+		}
+		out[s.UserID] = u
+	}
+	return out
+}
+
+// Together returns how long each pair of members spent in the same desk at
+// the same time, keyed by the two user IDs in ascending order.
+func Together(sessions []Session) map[[2]string]time.Duration {
+	byChannel := make(map[string][]Session)
+	for _, s := range sessions {
+		byChannel[s.ChannelID] = append(byChannel[s.ChannelID], s)
+	}
+
+	out := make(map[[2]string]time.Duration)
+	for _, group := range byChannel {
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if a.UserID == b.UserID {
+					continue
+				}
+				start, end := a.Start, a.End
+				if b.Start.After(start) {
+					start = b.Start
+				}
+				if b.End.Before(end) {
+					end = b.End
+				}
+				if !end.After(start) {
+					continue
+				}
+				key := [2]string{a.UserID, b.UserID}
+				if key[1] < key[0] {
+					key[0], key[1] = key[1], key[0]
+				}
+				out[key] += end.Sub(start)
+			}
+		}
+	}
+	return out
+}
+
+// WeekStart returns midnight on the Monday of the week containing t, in t's
+// location.
+func WeekStart(t time.Time) time.Time {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7 // Sunday → 7
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d-(weekday-1), 0, 0, 0, 0, t.Location())
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// load reads persisted sessions from disk. Missing file is treated as empty.
+// A file in the older format, a single JSON object of every guild's
+// sessions, is read and rewritten as a log.
+func (l *SessionLog) load() error {
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("desks: read %s: %w", l.path, err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if json.Unmarshal(data, &l.guilds) == nil {
+		return l.compact()
+	}
+
+	l.guilds = make(map[string][]Session)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var line logLine
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// The last append was cut short; drop it with the next compaction.
+			l.lines++
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("desks: parse %s: %w", l.path, err)
+		}
+		l.guilds[line.GuildID] = append(l.guilds[line.GuildID], line.Session)
+		l.lines++
+	}
+	// Drop what Record pruned after the file was last compacted.
+	for guildID, sessions := range l.guilds {
+		if len(sessions) == 0 {
+			continue
+		}
+		cutoff := sessions[len(sessions)-1].End.Add(-SessionRetention)
+		l.guilds[guildID] = slices.DeleteFunc(sessions, func(s Session) bool {
+			return s.End.Before(cutoff)
+		})
+	}
+	if l.lines > l.count() {
+		return l.compact()
+	}
+	return nil
+}
+
+// appendLine adds one session to the end of the file.
+// Caller must hold l.mu.
+func (l *SessionLog) appendLine(guildID string, session Session) error {
+	data, err := json.Marshal(logLine{GuildID: guildID, Session: session})
+	if err != nil {
+		return fmt.Errorf("desks: marshal session: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("desks: open %s: %w", l.path, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("desks: append to %s: %w", l.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("desks: close %s: %w", l.path, err)
+	}
+	l.lines++
+	return nil
+}
+
+// compact atomically rewrites the file with only the sessions still kept.
+// Caller must hold l.mu.
+func (l *SessionLog) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Write guilds in a stable order so compaction is deterministic.
+	for _, guildID := range slices.Sorted(maps.Keys(l.guilds)) {
+		for _, session := range l.guilds[guildID] {
+			if err := enc.Encode(logLine{GuildID: guildID, Session: session}); err != nil {
+				return fmt.Errorf("desks: marshal session: %w", err)
+			}
+		}
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("desks: write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("desks: rename to %s: %w", l.path, err)
+	}
+	l.lines = l.count()
+	return nil
+}
+
+// count returns how many sessions are kept across all guilds.
+// Caller must hold l.mu.
+func (l *SessionLog) count() int {
+	n := 0
+	for _, sessions := range l.guilds {
+		n += len(sessions)
+	}
+	return n
+}
@@ -0,0 +1,216 @@
+package desks
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSessionLog(t *testing.T) (*SessionLog, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "desk_sessions.json")
+	l, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog: %v", err)
+	}
+	return l, path
+}
+
+// --- SessionLog -------------------------------------------------------------
+
+func TestSessionLog_RecordAndQuery(t *testing.T) {
+	l, path := newTestSessionLog(t)
+	monday := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+
+	_ = l.Record("g1", Session{UserID: "u1", ChannelID: "c1", Start: monday, End: monday.Add(time.Hour)})
+	_ = l.Record("g1", Session{UserID: "u1", ChannelID: "c1", Start: monday.AddDate(0, 0, 7), End: monday.AddDate(0, 0, 7).Add(time.Hour)})
+
+	if got := l.Sessions("g1", monday, monday.AddDate(0, 0, 7)); len(got) != 1 {
+		t.Errorf("want 1 session in the first week, got %d", len(got))
+	}
+
+	reloaded, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog (reload): %v", err)
+	}
+	if got := reloaded.Sessions("g1", monday, monday.AddDate(0, 0, 14)); len(got) != 2 {
+		t.Errorf("after reload: want 2 sessions, got %d", len(got))
+	}
+}
+
+func TestSessionLog_PrunesOldSessions(t *testing.T) {
+	l, _ := newTestSessionLog(t)
+	old := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	recent := old.Add(SessionRetention + 24*time.Hour)
+
+	_ = l.Record("g1", Session{UserID: "u1", Start: old, End: old.Add(time.Hour)})
+	_ = l.Record("g1", Session{UserID: "u1", Start: recent, End: recent.Add(time.Hour)})
+
+	if got := l.Sessions("g1", time.Time{}, recent.Add(time.Hour)); len(got) != 1 {
+		t.Errorf("want old session pruned, got %d sessions", len(got))
+	}
+}
+
+func TestSessionLog_IgnoresEmptySessions(t *testing.T) {
+	l, _ := newTestSessionLog(t)
+	now := time.Now()
+
+	_ = l.Record("g1", Session{UserID: "u1", Start: now, End: now})
+	if got := l.Sessions("g1", now.Add(-time.Hour), now.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("want zero-length session dropped, got %d", len(got))
+	}
+}
+
+func TestSessionLog_AppendsAndCompacts(t *testing.T) {
+	l, path := newTestSessionLog(t)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	// One session a day for a year: older ones keep falling out of the
+	// retention window, so the file must be compacted along the way.
+	const days = 365
+	for d := range days {
+		at := start.AddDate(0, 0, d)
+		if err := l.Record("g1", Session{UserID: "u1", ChannelID: "c1", Start: at, End: at.Add(time.Hour)}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := len(l.Sessions("g1", time.Time{}, start.AddDate(0, 0, days)))
+	if lines := bytes.Count(data, []byte("\n")); lines > 2*kept {
+		t.Errorf("file has %d lines for %d sessions, want it compacted", lines, kept)
+	}
+
+	reloaded, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog (reload): %v", err)
+	}
+	if got := len(reloaded.Sessions("g1", time.Time{}, start.AddDate(0, 0, days))); got != kept {
+		t.Errorf("after reload: want %d sessions, got %d", kept, got)
+	}
+}
+
+func TestSessionLog_ReadsOldFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "desk_sessions.json")
+	monday := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+	old := `{"g1": [{"user_id": "u1", "channel_id": "c1", "start": "2026-04-06T09:00:00Z", "end": "2026-04-06T10:00:00Z"}]}`
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog: %v", err)
+	}
+	_ = l.Record("g1", Session{UserID: "u2", ChannelID: "c1", Start: monday, End: monday.Add(time.Hour)})
+
+	reloaded, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog (reload): %v", err)
+	}
+	if got := reloaded.Sessions("g1", monday, monday.AddDate(0, 0, 1)); len(got) != 2 {
+		t.Errorf("want both sessions after converting the file, got %d", len(got))
+	}
+}
+
+func TestSessionLog_DropsTornLastLine(t *testing.T) {
+	l, path := newTestSessionLog(t)
+	monday := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+	_ = l.Record("g1", Session{UserID: "u1", ChannelID: "c1", Start: monday, End: monday.Add(time.Hour)})
+
+	// Simulate a crash part-way through the next append.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"guild_id":"g1","user_id":"u2","cha`)
+	f.Close()
+
+	reloaded, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog: %v", err)
+	}
+	_ = reloaded.Record("g1", Session{UserID: "u3", ChannelID: "c1", Start: monday, End: monday.Add(time.Hour)})
+	again, err := NewSessionLog(path)
+	if err != nil {
+		t.Fatalf("NewSessionLog (again): %v", err)
+	}
+	if got := again.Sessions("g1", monday, monday.AddDate(0, 0, 1)); len(got) != 2 {
+		t.Errorf("want the two whole sessions, got %d", len(got))
+	}
+}
+
+// --- UsageBetween -----------------------------------------------------------
+
+func TestUsageBetween(t *testing.T) {
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+	nextMonday := monday.AddDate(0, 0, 7)
+
+	sessions := []Session{
+		// Own desk, straddling the start of the week: only 1h counts.
+		{UserID: "u1", OwnerID: "u1", Start: monday.Add(-time.Hour), End: monday.Add(time.Hour)},
+		// Visiting u2's desk for 30m.
+		{UserID: "u1", OwnerID: "u2", Start: monday.Add(2 * time.Hour), End: monday.Add(150 * time.Minute)},
+		// Entirely outside the week.
+		{UserID: "u2", OwnerID: "u2", Start: nextMonday, End: nextMonday.Add(time.Hour)},
+	}
+
+	usage := UsageBetween(sessions, monday, nextMonday)
+
+	if got := usage["u1"]; got.Own != time.Hour || got.Visiting != 30*time.Minute {
+		t.Errorf("u1 usage: got %+v", got)
+	}
+	if got := usage["u1"].Total(); got != 90*time.Minute {
+		t.Errorf("u1 total: want 1h30m, got %v", got)
+	}
+	if _, ok := usage["u2"]; ok {
+		t.Error("u2 should have no usage this week")
+	}
+}
+
+// --- Together ---------------------------------------------------------------
+
+func TestTogether(t *testing.T) {
+	start := time.Date(2026, 4, 6, 9, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		{UserID: "u1", ChannelID: "c1", Start: start, End: start.Add(2 * time.Hour)},
+		// u2 overlaps u1 for 1h in c1.
+		{UserID: "u2", ChannelID: "c1", Start: start.Add(time.Hour), End: start.Add(3 * time.Hour)},
+		// u3 is in a different desk at the same time.
+		{UserID: "u3", ChannelID: "c2", Start: start, End: start.Add(3 * time.Hour)},
+		// u1 comes back to c1 and overlaps u2 for another 30m.
+		{UserID: "u1", ChannelID: "c1", Start: start.Add(150 * time.Minute), End: start.Add(4 * time.Hour)},
+	}
+
+	together := Together(sessions)
+
+	if got := together[[2]string{"u1", "u2"}]; got != 90*time.Minute {
+		t.Errorf("u1/u2: want 1h30m, got %v", got)
+	}
+	if len(together) != 1 {
+		t.Errorf("want only the u1/u2 pair, got %v", together)
+	}
+}
+
+// --- WeekStart --------------------------------------------------------------
+
+func TestWeekStart(t *testing.T) {
+	cases := []struct {
+		in   time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 4, 6, 15, 0, 0, 0, time.UTC), time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 4, 8, 9, 0, 0, 0, time.UTC), time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 4, 12, 23, 0, 0, 0, time.UTC), time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		if got := WeekStart(tc.in); !got.Equal(tc.want) {
+			t.Errorf("WeekStart(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
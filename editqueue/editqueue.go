@@ -0,0 +1,296 @@
+// Package editqueue runs Discord edits one guild at a time.
+//
+// Each guild gets its own queue, so a burst of edits in one guild can't
+// starve another and never has more than one request in flight against a
+// guild's rate-limit buckets. Waiting edits with the same key are coalesced,
+// so when a desk is shown and hidden again before either edit runs only the
+// latest is sent. Transient failures are retried with exponential backoff.
+// Callers that need to know how an edit went can wait for it with Do.
+package editqueue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Defaults for the zero values in Options.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 30 * time.Second
+)
+
+var (
+	// ErrClosed is returned by Enqueue and Do once Close has been called,
+	// and by Do for a task Close dropped before it ran.
+	ErrClosed = errors.New("editqueue: closed")
+	// ErrSuperseded is returned by Do when a newer task with the same key
+	// replaced the task before it finished.
+	ErrSuperseded = errors.New("editqueue: superseded")
+)
+
+// Task is one edit. It should read the current state of whatever it edits
+// when it runs, rather than when it was queued, as it may run much later.
+type Task func() error
+
+// Options configures a Queue.
+type Options struct {
+	// Retryable reports whether a failed task is worth trying again, and how
+	// long the server asked to wait first, or 0 to use the backoff delay.
+	// When nil, no task is retried.
+	Retryable func(err error) (retry bool, after time.Duration)
+	// MaxAttempts bounds how often a task is tried, including the first.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles for each
+	// retry after that, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// OnRetry is called before waiting to retry a failed task. It may be nil.
+	OnRetry func(guildID, key string, err error, after time.Duration)
+	// OnError is called when a task fails for good. It may be nil.
+	OnError func(guildID, key string, err error)
+	// OnCoalesce is called when a waiting task is replaced by a newer one
+	// with the same key. It may be nil.
+	OnCoalesce func(guildID, key string)
+}
+
+// Queue runs tasks per guild. It is safe for concurrent use.
+type Queue struct {
+	opts Options
+
+	mu     sync.Mutex
+	guilds map[string]*guildQueue
+	closed bool
+	wg     sync.WaitGroup // running guild workers
+
+	ctx    context.Context // cancelled to abandon backoffs on Close
+	cancel context.CancelFunc
+}
+
+// guildQueue holds one guild's waiting tasks in the order they were first
+// queued.
+type guildQueue struct {
+	keys    []string
+	tasks   map[string]*entry
+	running bool
+}
+
+// entry is a queued task and where to send its outcome. done is buffered so
+// the worker never waits for a caller that stopped listening.
+type entry struct {
+	task Task
+	done chan error
+}
+
+// New returns an empty Queue.
+func New(opts Options) *Queue {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		opts:   opts,
+		guilds: make(map[string]*guildQueue),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Enqueue queues task for the guild under key, e.g. the ID of the channel it
+// edits. If a task with the same key is still waiting it is replaced and
+// keeps its place in line; a task that is already running is not affected.
+func (q *Queue) Enqueue(guildID, key string, task Task) error {
+	_, err := q.enqueue(guildID, key, task)
+	return err
+}
+
+// Do queues task like Enqueue and waits for it to finish, returning its
+// final error after any retries. It returns ErrSuperseded if a newer task
+// with the same key replaced it first. If ctx is done first, Do returns
+// ctx's error and the task stays queued.
+func (q *Queue) Do(ctx context.Context, guildID, key string, task Task) error {
+	done, err := q.enqueue(guildID, key, task)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending returns how many tasks are waiting for the guild, not counting
+// one that is running.
+func (q *Queue) Pending(guildID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if g, ok := q.guilds[guildID]; ok {
+		return len(g.keys)
+	}
+	return 0
+}
+
+// Close stops accepting tasks and waits for queued ones to finish. If ctx is
+// done first, tasks still waiting are dropped, backoffs are abandoned and
+// ctx's error is returned.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		for _, g := range q.guilds {
+			for _, e := range g.tasks {
+				e.done <- ErrClosed
+			}
+			g.keys, g.tasks = nil, make(map[string]*entry)
+		}
+		q.mu.Unlock()
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// enqueue queues task and returns the channel its outcome is sent on.
+func (q *Queue) enqueue(guildID, key string, task Task) (<-chan error, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrClosed
+	}
+	g, ok := q.guilds[guildID]
+	if !ok {
+		g = &guildQueue{tasks: make(map[string]*entry)}
+		q.guilds[guildID] = g
+	}
+	if replaced, waiting := g.tasks[key]; waiting {
+		replaced.done <- ErrSuperseded
+		if q.opts.OnCoalesce != nil {
+			q.opts.OnCoalesce(guildID, key)
+		}
+	} else {
+		g.keys = append(g.keys, key)
+	}
+	e := &entry{task: task, done: make(chan error, 1)}
+	g.tasks[key] = e
+
+	if !g.running {
+		g.running = true
+		q.wg.Add(1)
+		go q.run(guildID, g)
+	}
+	return e.done, nil
+}
+
+// run works through a guild's tasks until none are left.
+func (q *Queue) run(guildID string, g *guildQueue) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		if len(g.keys) == 0 {
+			g.running = false
+			q.mu.Unlock()
+			return
+		}
+		key := g.keys[0]
+		g.keys = slices.Delete(g.keys, 0, 1)
+		e := g.tasks[key]
+		delete(g.tasks, key)
+		q.mu.Unlock()
+
+		e.done <- q.attempt(guildID, key, e.task)
+	}
+}
+
+// attempt runs a task, retrying transient failures, and returns its final
+// error. A retry is skipped if a newer task with the same key has been queued
+// meanwhile, as that one supersedes it.
+func (q *Queue) attempt(guildID, key string, task Task) error {
+	for n := 1; ; n++ {
+		err := task()
+		if err == nil {
+			return nil
+		}
+
+		retry, after := false, time.Duration(0)
+		if q.opts.Retryable != nil {
+			retry, after = q.opts.Retryable(err)
+		}
+		if !retry || n >= q.opts.MaxAttempts {
+			q.fail(guildID, key, err)
+			return err
+		}
+		if after <= 0 {
+			after = q.backoff(n)
+		}
+		if q.opts.OnRetry != nil {
+			q.opts.OnRetry(guildID, key, err, after)
+		}
+		if !q.sleep(after) {
+			q.fail(guildID, key, err)
+			return err
+		}
+		if q.superseded(guildID, key) {
+			return ErrSuperseded
+		}
+	}
+}
+
+// backoff returns the delay before retry n (counting from 1).
+func (q *Queue) backoff(n int) time.Duration {
+	d := q.opts.BaseDelay << (n - 1)
+	if d <= 0 || d > q.opts.MaxDelay {
+		return q.opts.MaxDelay
+	}
+	return d
+}
+
+// sleep waits for d, returning false if the queue is abandoned first.
+func (q *Queue) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-q.ctx.Done():
+		return false
+	}
+}
+
+// superseded reports whether a newer task for key is waiting.
+func (q *Queue) superseded(guildID, key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, waiting := q.guilds[guildID].tasks[key]
+	return waiting
+}
+
+func (q *Queue) fail(guildID, key string, err error) {
+	if q.opts.OnError != nil {
+		q.opts.OnError(guildID, key, err)
+	}
+}
@@ -0,0 +1,403 @@
+package editqueue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+// retryTransient retries errTransient only.
+func retryTransient(err error) (bool, time.Duration) {
+	return errors.Is(err, errTransient), 0
+}
+
+// recorder collects the names of tasks as they run.
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) task(name string) Task {
+	return func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ran = append(r.ran, name)
+		return nil
+	}
+}
+
+func (r *recorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.ran)
+}
+
+// block queues a task that holds up the guild until the returned function is
+// called, so later tasks stay waiting.
+func block(t *testing.T, q *Queue, guildID string) (release func()) {
+	t.Helper()
+	started, unblock := make(chan struct{}), make(chan struct{})
+	err := q.Enqueue(guildID, "block", func() error {
+		close(started)
+		<-unblock
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	return func() { close(unblock) }
+}
+
+func closeQueue(t *testing.T, q *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+// --- Enqueue ---
+
+func TestEnqueue_RunsInOrder(t *testing.T) {
+	q := New(Options{})
+	var r recorder
+
+	release := block(t, q, "g1")
+	for _, name := range []string{"a", "b", "c"} {
+		if err := q.Enqueue("g1", name, r.task(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := q.Pending("g1"); got != 3 {
+		t.Errorf("Pending: got %d, want 3", got)
+	}
+	release()
+	closeQueue(t, q)
+
+	if got, want := r.names(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEnqueue_CoalescesWaitingTasks(t *testing.T) {
+	var coalesced []string
+	q := New(Options{OnCoalesce: func(guildID, key string) {
+		coalesced = append(coalesced, key)
+	}})
+	var r recorder
+
+	release := block(t, q, "g1")
+	q.Enqueue("g1", "ch1", r.task("show ch1"))
+	q.Enqueue("g1", "ch2", r.task("show ch2"))
+	q.Enqueue("g1", "ch1", r.task("hide ch1"))
+	if got := q.Pending("g1"); got != 2 {
+		t.Errorf("Pending: got %d, want 2", got)
+	}
+	release()
+	closeQueue(t, q)
+
+	// The newer task for ch1 keeps the older one's place in line.
+	if got, want := r.names(), []string{"hide ch1", "show ch2"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !slices.Equal(coalesced, []string{"ch1"}) {
+		t.Errorf("OnCoalesce: got %v, want [ch1]", coalesced)
+	}
+}
+
+func TestEnqueue_GuildsAreIndependent(t *testing.T) {
+	q := New(Options{})
+	var r recorder
+
+	release := block(t, q, "g1")
+	defer release()
+
+	done := make(chan struct{})
+	q.Enqueue("g2", "ch1", func() error {
+		defer close(done)
+		return r.task("g2")()
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked guild held up another guild")
+	}
+}
+
+func TestEnqueue_AfterClose(t *testing.T) {
+	q := New(Options{})
+	closeQueue(t, q)
+
+	if err := q.Enqueue("g1", "ch1", func() error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
+
+// --- Do ---
+
+func TestDo_ReturnsOutcome(t *testing.T) {
+	errForbidden := errors.New("forbidden")
+	q := New(Options{Retryable: retryTransient, BaseDelay: time.Millisecond})
+	defer closeQueue(t, q)
+	ctx := context.Background()
+
+	if err := q.Do(ctx, "g1", "ch1", func() error { return nil }); err != nil {
+		t.Errorf("success: got %v, want nil", err)
+	}
+	if err := q.Do(ctx, "g1", "ch1", func() error { return errForbidden }); err != errForbidden {
+		t.Errorf("permanent failure: got %v, want %v", err, errForbidden)
+	}
+
+	attempts := 0
+	err := q.Do(ctx, "g1", "ch1", func() error {
+		attempts++
+		if attempts < 2 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("retried: got %v after %d attempts, want nil after 2", err, attempts)
+	}
+}
+
+func TestDo_Superseded(t *testing.T) {
+	q := New(Options{})
+	var r recorder
+
+	release := block(t, q, "g1")
+	outcome := make(chan error, 1)
+	go func() {
+		outcome <- q.Do(context.Background(), "g1", "ch1", r.task("rename ch1"))
+	}()
+	for q.Pending("g1") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	q.Enqueue("g1", "ch1", r.task("rename ch1 again"))
+
+	if err := <-outcome; !errors.Is(err, ErrSuperseded) {
+		t.Errorf("got %v, want ErrSuperseded", err)
+	}
+	release()
+	closeQueue(t, q)
+
+	if got, want := r.names(), []string{"rename ch1 again"}; !slices.Equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestDo_ContextDone(t *testing.T) {
+	q := New(Options{})
+	var r recorder
+
+	release := block(t, q, "g1")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Do(ctx, "g1", "ch1", r.task("ch1")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	release()
+	closeQueue(t, q)
+
+	// The task still runs after the caller stops waiting.
+	if got, want := r.names(), []string{"ch1"}; !slices.Equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestDo_AfterClose(t *testing.T) {
+	q := New(Options{})
+	closeQueue(t, q)
+
+	if err := q.Do(context.Background(), "g1", "ch1", func() error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
+
+// --- retries ---
+
+func TestRetry_TransientFailures(t *testing.T) {
+	var retries []time.Duration
+	q := New(Options{
+		Retryable: retryTransient,
+		BaseDelay: time.Millisecond,
+		OnRetry: func(guildID, key string, err error, after time.Duration) {
+			retries = append(retries, after)
+		},
+		OnError: func(guildID, key string, err error) {
+			t.Errorf("OnError: %v", err)
+		},
+	})
+
+	attempts := 0
+	q.Enqueue("g1", "ch1", func() error {
+		attempts++
+		if attempts < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	closeQueue(t, q)
+
+	if attempts != 3 {
+		t.Errorf("attempts: got %d, want 3", attempts)
+	}
+	if want := []time.Duration{time.Millisecond, 2 * time.Millisecond}; !slices.Equal(retries, want) {
+		t.Errorf("backoff: got %v, want %v", retries, want)
+	}
+}
+
+func TestRetry_UsesRetryAfter(t *testing.T) {
+	var waited time.Duration
+	q := New(Options{
+		Retryable: func(err error) (bool, time.Duration) { return true, 3 * time.Millisecond },
+		OnRetry: func(guildID, key string, err error, after time.Duration) {
+			waited = after
+		},
+	})
+
+	failed := false
+	q.Enqueue("g1", "ch1", func() error {
+		if !failed {
+			failed = true
+			return errTransient
+		}
+		return nil
+	})
+	closeQueue(t, q)
+
+	if waited != 3*time.Millisecond {
+		t.Errorf("got %v, want 3ms", waited)
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	var failures []error
+	q := New(Options{
+		Retryable:   retryTransient,
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		OnError: func(guildID, key string, err error) {
+			failures = append(failures, err)
+		},
+	})
+
+	attempts := 0
+	q.Enqueue("g1", "ch1", func() error {
+		attempts++
+		return errTransient
+	})
+	closeQueue(t, q)
+
+	if attempts != 3 {
+		t.Errorf("attempts: got %d, want 3", attempts)
+	}
+	if len(failures) != 1 || !errors.Is(failures[0], errTransient) {
+		t.Errorf("OnError: got %v", failures)
+	}
+}
+
+func TestRetry_PermanentFailure(t *testing.T) {
+	errForbidden := errors.New("forbidden")
+	var failures []error
+	q := New(Options{
+		Retryable: retryTransient,
+		OnError: func(guildID, key string, err error) {
+			failures = append(failures, err)
+		},
+	})
+
+	attempts := 0
+	q.Enqueue("g1", "ch1", func() error {
+		attempts++
+		return errForbidden
+	})
+	closeQueue(t, q)
+
+	if attempts != 1 {
+		t.Errorf("attempts: got %d, want 1", attempts)
+	}
+	if len(failures) != 1 || failures[0] != errForbidden {
+		t.Errorf("OnError: got %v", failures)
+	}
+}
+
+func TestRetry_SupersededByNewerTask(t *testing.T) {
+	retrying := make(chan struct{})
+	q := New(Options{
+		Retryable: func(err error) (bool, time.Duration) { return true, 50 * time.Millisecond },
+		OnRetry: func(guildID, key string, err error, after time.Duration) {
+			close(retrying)
+		},
+	})
+	var r recorder
+
+	q.Enqueue("g1", "ch1", func() error {
+		r.task("show ch1")()
+		return errTransient
+	})
+	<-retrying
+	q.Enqueue("g1", "ch1", r.task("hide ch1"))
+	closeQueue(t, q)
+
+	if got, want := r.names(), []string{"show ch1", "hide ch1"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBackoff_Capped(t *testing.T) {
+	q := New(Options{BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 80: 5 * time.Second} {
+		if got := q.backoff(n); got != want {
+			t.Errorf("backoff(%d): got %v, want %v", n, got, want)
+		}
+	}
+}
+
+// --- Close ---
+
+func TestClose_Deadline(t *testing.T) {
+	var failures []error
+	q := New(Options{
+		Retryable: func(err error) (bool, time.Duration) { return true, time.Hour },
+		OnError: func(guildID, key string, err error) {
+			failures = append(failures, err)
+		},
+	})
+	var r recorder
+
+	q.Enqueue("g1", "ch1", func() error { return errTransient })
+	dropped := make(chan error, 1)
+	go func() {
+		dropped <- q.Do(context.Background(), "g1", "ch2", r.task("ch2"))
+	}()
+	for q.Pending("g1") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close: got %v, want DeadlineExceeded", err)
+	}
+	q.wg.Wait()
+
+	if err := <-dropped; !errors.Is(err, ErrClosed) {
+		t.Errorf("Do for a dropped task: got %v, want ErrClosed", err)
+	}
+
+	if got := r.names(); len(got) != 0 {
+		t.Errorf("dropped tasks ran: %v", got)
+	}
+	if len(failures) != 1 {
+		t.Errorf("OnError: got %v, want the abandoned retry", failures)
+	}
+}
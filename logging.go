@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ---------------------------------------------------------------------------
+// Logging
+// ---------------------------------------------------------------------------
+
+// logger is the bot's structured logger. main replaces it with one built
+// from the config before anything else logs.
+var logger = slog.Default()
+
+// newLogger returns a logger writing to w at level ("debug", "info", "warn"
+// or "error") as text or, when format is "json", one JSON object per line.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), nil
+}
+
+// interactionLogger returns logger annotated with who used which command
+// where, so every line about an interaction can be traced back to it.
+func interactionLogger(i *discordgo.InteractionCreate) *slog.Logger {
+	attrs := []any{"guild", i.GuildID, "channel", i.ChannelID}
+	if user := interactionUser(i); user != nil {
+		attrs = append(attrs, "user", user.ID)
+	}
+	if name := interactionName(i); name != "" {
+		attrs = append(attrs, "command", name)
+	}
+	return logger.With(attrs...)
+}
+
+// interactionUser returns the user behind an interaction, whether it came
+// from a guild or a DM.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// interactionName describes an interaction for logs: the full command path
+// such as "prbuddy pto me" for commands, or the custom ID for components
+// and modals.
+func interactionName(i *discordgo.InteractionCreate) string {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		data := i.ApplicationCommandData()
+		parts := []string{data.Name}
+		opts := data.Options
+		for len(opts) > 0 && (opts[0].Type == discordgo.ApplicationCommandOptionSubCommand ||
+			opts[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup) {
+			parts = append(parts, opts[0].Name)
+			opts = opts[0].Options
+		}
+		return strings.Join(parts, " ")
+	case discordgo.InteractionMessageComponent:
+		return i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		return i.ModalSubmitData().CustomID
+	}
+	return ""
+}
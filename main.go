@@ -1,89 +1,193 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/announce"
+	"github.com/cbarber/deskbot/audit"
+	"github.com/cbarber/deskbot/config"
+	"github.com/cbarber/deskbot/desks"
 	"github.com/cbarber/deskbot/prbuddy"
 )
 
 const (
-	USER_DESK_PERMISSIONS int64 = discordgo.PermissionViewChannel | discordgo.PermissionManageChannels
-	BOT_DESK_PERMISSIONS  int64 = discordgo.PermissionViewChannel
+	USER_DESK_PERMISSIONS  int64 = discordgo.PermissionViewChannel | discordgo.PermissionManageChannels | discordgo.PermissionVoiceConnect
+	BOT_DESK_PERMISSIONS   int64 = discordgo.PermissionViewChannel
+	GUEST_DESK_PERMISSIONS int64 = discordgo.PermissionViewChannel | discordgo.PermissionVoiceConnect
+	NOTES_DESK_PERMISSIONS int64 = discordgo.PermissionSendMessages | discordgo.PermissionReadMessageHistory
 )
 
+// defaultConfigPath is read if it exists when neither -config nor
+// DESKBOT_CONFIG names a file.
+const defaultConfigPath = "deskbot.yaml"
+
 var (
+	configPath               string
 	token                    string
+	devGuildID               string
+	clearGlobalCommands      bool
+	cfg                      config.Config
 	guildToDeskCategory      *sync.Map
 	guildChannelMembersMutex *sync.Mutex
 	guildChannelMembers      map[string](map[string]int)
 
-	buddy *prbuddy.Bot
+	buddy      *prbuddy.Bot
+	deskStore  *desks.Store
+	presence   *desks.Presence
+	sessionLog *desks.SessionLog
 )
 
 func init() {
-	flag.StringVar(&token, "t", "", "Bot Token")
-	flag.Parse()
+	flag.StringVar(&configPath, "config", "", "Config file (default $DESKBOT_CONFIG or "+defaultConfigPath+")")
+	flag.StringVar(&token, "t", "", "Bot Token (overrides the config file and $DESKBOT_TOKEN)")
+	flag.StringVar(&devGuildID, "dev-guild", "", "Register commands in this guild only and remove them on exit (for development)")
+	flag.BoolVar(&clearGlobalCommands, "clear-global-commands", false, "With a dev guild, also remove the bot's global commands left by a run without one")
+}
+
+// loadConfig reads the config file and environment. Flags win over both.
+func loadConfig() (config.Config, error) {
+	path, required := configPath, true
+	if path == "" {
+		path = os.Getenv("DESKBOT_CONFIG")
+	}
+	if path == "" {
+		path, required = defaultConfigPath, false
+	}
+	return config.Load(path, required, func(name string) string {
+		switch {
+		case name == "DESKBOT_TOKEN" && token != "":
+			return token
+		case name == "DESKBOT_DEV_GUILD" && devGuildID != "":
+			return devGuildID
+		}
+		return os.Getenv(name)
+	})
 }
 
 func main() {
-	if token == "" {
-		fmt.Println("No token provided. Please run: airhorn -t <bot token>")
-		return
+	flag.Parse()
+
+	var err error
+	cfg, err = loadConfig()
+	if err != nil {
+		// No logger yet; print the readable multi-line error as is.
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger, err = newLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	discord, err := discordgo.New("Bot " + token)
+	discord, err := discordgo.New("Bot " + cfg.Token)
 	if err != nil {
-		fmt.Println("Error creating Discord session: ", err)
+		logger.Error("Failed to create Discord session", "err", err)
 		return
 	}
 
-	buddy, err = prbuddy.New("./prbuddy.json", func(guildID string, result prbuddy.Result) {
+	countAPIErrors(discord)
+
+	buddy, err = prbuddy.New(cfg.State.PRBuddy, func(guildID string, result prbuddy.Result) {
+		pairingRuns.Inc(guildID, "schedule")
+		recordAudit(discord, guildID, audit.Entry{Action: "Pairings generated", After: formatPairs(result)})
 		postPairings(discord, guildID, result)
-	})
+		if cfg.Features.PairingDMs {
+			auditDMFailures(discord, guildID, dmPairings(discord, guildID, result))
+		}
+	},
+		prbuddy.WithWeekSeeding(),
+		prbuddy.WithPairingSchedule(cfg.Schedule.Pairings),
+		prbuddy.WithLocation(cfg.Location()),
+		prbuddy.WithLogger(logger),
+	)
+	if err != nil {
+		logger.Error("Failed to initialise PR buddy", "err", err)
+		return
+	}
+
+	deskStore, err = desks.New(cfg.State.Desks)
 	if err != nil {
-		fmt.Println("Error initialising PR buddy:", err)
+		logger.Error("Failed to initialise desk store", "err", err)
 		return
 	}
 
+	sessionLog, err = desks.NewSessionLog(cfg.State.DeskSessions)
+	if err != nil {
+		logger.Error("Failed to initialise desk session log", "err", err)
+		return
+	}
+	auditLog, err = audit.New(cfg.State.Audit)
+	if err != nil {
+		logger.Error("Failed to initialise audit log", "err", err)
+		return
+	}
+	buddy.SetAffinity(deskAffinity)
+	buddy.SetReminderFunc(func(guildID string, reminder prbuddy.Reminder) {
+		postReminder(discord, guildID, reminder)
+	})
+
 	discord.AddHandler(ready)
-	discord.AddHandler(guildCreate)
-	discord.AddHandler(guildMemberAdd)
-	discord.AddHandler(voiceStateUpdate)
-	discord.AddHandler(interactionCreate)
+	if cfg.Features.Desks {
+		discord.AddHandler(tracked(guildCreate))
+		discord.AddHandler(tracked(guildMemberAdd))
+		discord.AddHandler(tracked(guildMemberUpdate))
+		discord.AddHandler(tracked(voiceStateUpdate))
+		discord.AddHandler(tracked(channelDelete))
+	}
+	discord.AddHandler(tracked(interactionCreate))
 
 	discord.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMembers | discordgo.IntentsGuildVoiceStates
 
 	// Open the websocket and begin listening.
 	err = discord.Open()
 	if err != nil {
-		fmt.Println("Error opening Discord session: ", err)
+		logger.Error("Failed to open Discord session", "err", err)
 		return
 	}
 
-	if err := registerCommands(discord); err != nil {
-		fmt.Println("Error registering slash commands:", err)
+	if err := registerCommands(discord, cfg.DevGuild); err != nil {
+		logger.Error("Failed to register slash commands", "err", err)
 		// Non-fatal — bot still works without slash commands.
 	}
+	if cfg.DevGuild != "" {
+		removeLeftoverGlobalCommands(discord, clearGlobalCommands)
+	}
 
-	buddy.StartScheduler()
+	if cfg.Features.PRBuddy {
+		buddy.StartScheduler()
+	}
+	stopHTTPServer := func() {}
+	if cfg.HTTPAddr != "" {
+		stopHTTPServer = startHTTPServer(discord, cfg.HTTPAddr)
+	}
+	stopIdleSweeper := func() {}
+	if cfg.Features.Desks && cfg.Features.IdleSweeper {
+		stopIdleSweeper = startIdleSweeper(discord)
+	}
 
-	fmt.Println("Deskbot is now running.  Press CTRL-C to exit.")
+	logger.Info("Deskbot is now running. Press CTRL-C to exit.")
 
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
 
-	fmt.Println("Closing discord session...")
-	buddy.Stop()
-	endSession(discord)
+	logger.Info("Shutting down", "desks", cfg.Shutdown.Desks, "timeout", cfg.Shutdown.Timeout)
+	shutdown(discord, stopHTTPServer, stopIdleSweeper)
+	logger.Info("Closing discord session")
 	discord.Close()
 }
 
@@ -92,11 +196,12 @@ func main() {
 // ---------------------------------------------------------------------------
 
 func ready(s *discordgo.Session, event *discordgo.Ready) {
-	fmt.Println("ready")
+	logger.Info("ready", "user", event.User.ID)
 
 	guildToDeskCategory = new(sync.Map)
 	guildChannelMembersMutex = new(sync.Mutex)
 	guildChannelMembers = make(map[string](map[string]int))
+	presence = desks.NewPresence()
 }
 
 func guildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
@@ -104,28 +209,32 @@ func guildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
 		return
 	}
 
-	fmt.Println("guildCreate", event.Name)
+	logger.Info("guildCreate", "guild", event.ID, "name", event.Name)
+
+	if cfg.DevGuild == "" {
+		removeLeftoverGuildCommands(s, event.ID)
+	}
 
 	defaultChannelId := event.SystemChannelID
 
 	if defaultChannelId == "" {
-		fmt.Println("Failed to find default channel for guild")
+		logger.Warn("Failed to find default channel for guild", "guild", event.ID)
 		return
 	}
 
 	var deskCategoryId string
 	for _, channel := range event.Channels {
-		if strings.ToLower(channel.Name) == "desks" && channel.Type == discordgo.ChannelTypeGuildCategory {
+		if strings.EqualFold(channel.Name, cfg.Channels.DeskCategory) && channel.Type == discordgo.ChannelTypeGuildCategory {
 			deskCategoryId = channel.ID
 			break
 		}
 	}
 
 	if deskCategoryId != "" {
-		fmt.Printf("Deskbot found the DESKS category in %v. Storing (guildId: %s, deskCategoryId: %s)\n", event.Name, event.ID, deskCategoryId)
+		logger.Info("Found the desk category", "guild", event.ID, "category", deskCategoryId)
 		guildToDeskCategory.Store(event.ID, deskCategoryId)
 	} else {
-		fmt.Printf("Deskbot failed to find the DESKS category in %v\n", event.Name)
+		logger.Warn("Failed to find the desk category", "guild", event.ID, "name", cfg.Channels.DeskCategory)
 		return
 	}
 
@@ -136,50 +245,133 @@ func guildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
 	}
 	guildChannelMembersMutex.Unlock()
 
+	deskChannelIds := make(map[string]bool)
+	for _, channel := range event.Channels {
+		if channel.ParentID == deskCategoryId {
+			deskChannelIds[channel.ID] = true
+		}
+	}
+	for _, voiceState := range event.VoiceStates {
+		if deskChannelIds[voiceState.ChannelID] {
+			presence.Join(event.ID, voiceState.UserID, voiceState.ChannelID, time.Now())
+		}
+	}
+
 	// TODO: paginate when mojo passes 1000 employees
-	members, err := s.GuildMembers(event.ID, "", 1000)
+	members, err := s.GuildMembers(event.ID, "", cfg.MemberLimit)
 	if err != nil {
-		fmt.Printf("Deskbot failed to fetch the first 1000 member of %s\n", event.Name)
+		logger.Error("Failed to fetch guild members", "guild", event.ID, "limit", cfg.MemberLimit, "err", err)
 		return
 	}
 
 	for _, member := range members {
-		if member.User.Bot || member.User.System {
-			continue
+		queueMemberDeskSync(s, event.Guild, deskCategoryId, member)
+	}
+
+	scheduleStatusBoardUpdate(s, event.ID)
+}
+
+// queueMemberDeskSync queues syncMemberDesk for a member. The sync reads the
+// guild's channels when it runs, so it sees desks created or changed by
+// edits queued ahead of it. A failed sync is run again, except when creating
+// the desk failed, as Discord may have created it anyway.
+func queueMemberDeskSync(s *discordgo.Session, guild *discordgo.Guild, deskCategoryId string, member *discordgo.Member) {
+	queueDeskEdit(guild.ID, "member:"+member.User.ID, func() error {
+		channels, err := guildChannels(s, guild.ID)
+		if err != nil {
+			return fmt.Errorf("fetch channels: %w", err)
 		}
+		return syncMemberDesk(s, guild, channels, deskCategoryId, member)
+	})
+}
 
-		deskChannel := findUserDeskChannel(event.Channels, deskCategoryId, member.User.ID, s.State.User.ID)
-		if deskChannel == nil {
-			fmt.Printf("Missing desk channel for user %s\n", member.DisplayName())
-			err := createDeskChannel(s, event.ID, member.User.ID, member.DisplayName(), deskCategoryId)
-			if err != nil {
-				fmt.Printf("Failed to create desk channel for user %s: %v\n", member.DisplayName(), err)
-				continue
-			}
-		} else {
-			if err := resetDeskPermissions(s, deskChannel, member.User.ID); err != nil {
-				fmt.Printf("Failed to reset desk permissions for user %s: %v\n", member.DisplayName(), err)
-				continue
-			}
+// syncMemberDesk brings a member's desk in line with their eligibility.
+// Eligible members get a desk, restored from the archive if they had opted
+// out before; ineligible members have any existing desk archived. It runs on
+// the desk edit queue, so it makes its edits directly rather than queueing
+// them behind itself.
+func syncMemberDesk(s *discordgo.Session, guild *discordgo.Guild, channels []*discordgo.Channel, deskCategoryId string, member *discordgo.Member) error {
+	if member.User.Bot || member.User.System {
+		return nil
+	}
+
+	deskChannel := findUserDeskChannel(guild.ID, channels, deskCategoryId, member.User.ID, s.State.User.ID)
 
-			guildChannelMembersMutex.Lock()
-			if guildChannelMembers[event.ID][deskChannel.ID] != 0 {
-				showDeskChannel(s, event.Guild, deskChannel)
-			} else {
-				hideDeskChannel(s, event.Guild, deskChannel)
+	if !deskStore.Eligible(guild.ID, member.User.ID, member.Roles) {
+		if deskChannel != nil && !isDeskArchived(guild.ID, deskChannel.ID) {
+			logger.Info("Archiving desk channel", "guild", guild.ID, "channel", deskChannel.ID, "user", member.User.ID)
+			if _, err := applyArchiveDesk(s, deskChannel, member.User.ID); err != nil {
+				return fmt.Errorf("archive desk channel %s: %w", deskChannel.ID, err)
 			}
-			guildChannelMembersMutex.Unlock()
+			recordAudit(s, guild.ID, audit.Entry{Action: "Desk archived", Target: userMention(member.User.ID), After: channelMention(deskChannel.ID)})
 		}
+		return nil
+	}
+
+	if deskChannel == nil {
+		logger.Info("Missing desk channel", "guild", guild.ID, "user", member.User.ID)
+		if err := createDeskChannel(s, guild.ID, member.User.ID, member.DisplayName(), deskCategoryId); err != nil {
+			return noRetry{fmt.Errorf("create desk channel: %w", err)}
+		}
+		auditDeskCreated(s, guild.ID, member.User.ID)
+		return nil
+	}
+
+	if isDeskArchived(guild.ID, deskChannel.ID) {
+		logger.Info("Restoring archived desk channel", "guild", guild.ID, "channel", deskChannel.ID, "user", member.User.ID)
+		restored, err := applyRestoreDesk(s, deskChannel, member.User.ID)
+		if err != nil {
+			return fmt.Errorf("restore desk channel %s: %w", deskChannel.ID, err)
+		}
+		recordAudit(s, guild.ID, audit.Entry{Action: "Desk restored", Target: userMention(member.User.ID), After: channelMention(deskChannel.ID)})
+		deskChannel = restored
+	}
+
+	if err := applyResetDeskPermissions(s, deskChannel, member.User.ID); err != nil {
+		return fmt.Errorf("reset desk permissions on %s: %w", deskChannel.ID, err)
+	}
+
+	guildChannelMembersMutex.Lock()
+	if guildChannelMembers[guild.ID][deskChannel.ID] != 0 {
+		showDeskChannel(s, guild, deskChannel)
+	} else {
+		hideDeskChannel(s, guild, deskChannel)
 	}
+	guildChannelMembersMutex.Unlock()
+	return nil
 }
 
-func endSession(s *discordgo.Session) {
-	for _, guild := range s.State.Guilds {
-		showAllDeskChannels(s, guild)
+// syncGuildDesks re-applies desk eligibility to every member of a guild.
+// It is used after a guild's desk roles change.
+func syncGuildDesks(s *discordgo.Session, guildID string) {
+	maybeDeskCategoryId, ok := guildToDeskCategory.Load(guildID)
+	if !ok {
+		logger.Warn("Failed to find desk category", "guild", guildID)
+		return
+	}
+	deskCategoryId := maybeDeskCategoryId.(string)
+
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		logger.Error("Failed to find guild", "guild", guildID, "err", err)
+		return
+	}
+
+	// TODO: paginate when mojo passes 1000 employees
+	members, err := s.GuildMembers(guildID, "", cfg.MemberLimit)
+	if err != nil {
+		logger.Error("Failed to fetch guild members", "guild", guildID, "limit", cfg.MemberLimit, "err", err)
+		return
+	}
+
+	for _, member := range members {
+		queueMemberDeskSync(s, guild, deskCategoryId, member)
 	}
 }
 
-func showAllDeskChannels(s *discordgo.Session, guild *discordgo.Guild) {
+// showAllDeskChannels shows every desk in the guild that isn't archived,
+// stopping early if ctx is done.
+func showAllDeskChannels(ctx context.Context, s *discordgo.Session, guild *discordgo.Guild) {
 	maybeDeskCategoryId, ok := guildToDeskCategory.Load(guild.ID)
 	if !ok {
 		return
@@ -187,7 +379,13 @@ func showAllDeskChannels(s *discordgo.Session, guild *discordgo.Guild) {
 	deskCategoryId := maybeDeskCategoryId.(string)
 
 	for _, channel := range guild.Channels {
-		if channel.ParentID == deskCategoryId && getChannelOwner(channel, s.State.User.ID) != "" {
+		if ctx.Err() != nil {
+			return
+		}
+		if channel.ParentID != deskCategoryId {
+			continue
+		}
+		if deskOwner(guild.ID, channel.ID) != "" && !isDeskArchived(guild.ID, channel.ID) {
 			showDeskChannel(s, guild, channel)
 		}
 	}
@@ -196,66 +394,112 @@ func showAllDeskChannels(s *discordgo.Session, guild *discordgo.Guild) {
 func guildMemberAdd(s *discordgo.Session, event *discordgo.GuildMemberAdd) {
 	name := event.DisplayName()
 
-	fmt.Println("guildMemberAdd", name)
+	logger.Info("guildMemberAdd", "guild", event.GuildID, "user", event.User.ID, "name", name)
 
 	maybeDeskCategoryId, ok := guildToDeskCategory.Load(event.GuildID)
 	if !ok {
-		fmt.Println("Failed to find deskCategory for guildId", event.GuildID)
+		logger.Warn("Failed to find desk category", "guild", event.GuildID)
 		return
 	}
 	deskCategoryId := maybeDeskCategoryId.(string)
 
 	channels, err := s.GuildChannels(event.GuildID)
 	if err != nil {
-		fmt.Println("Failed to fetch channels", err)
+		logger.Error("Failed to fetch channels", "guild", event.GuildID, "err", err)
 		return
 	}
 
-	existingDeskChannel := findUserDeskChannel(channels, deskCategoryId, event.User.ID, s.State.User.ID)
+	existingDeskChannel := findUserDeskChannel(event.GuildID, channels, deskCategoryId, event.User.ID, s.State.User.ID)
 
 	if existingDeskChannel != nil {
 		return
 	}
 
+	if !deskStore.Eligible(event.GuildID, event.User.ID, event.Roles) {
+		logger.Debug("Member is not eligible for a desk", "guild", event.GuildID, "user", event.User.ID)
+		return
+	}
+
 	err = createDeskChannel(s, event.GuildID, event.User.ID, name, deskCategoryId)
 	if err != nil {
-		fmt.Println("Failed to create channel", err)
+		logger.Error("Failed to create desk channel", "guild", event.GuildID, "user", event.User.ID, "err", err)
 		return
 	}
+	auditDeskCreated(s, event.GuildID, event.User.ID)
 
 	guild, err := s.Guild(event.GuildID)
 	if err != nil {
-		fmt.Println("Failed to find guild", err)
+		logger.Error("Failed to find guild", "guild", event.GuildID, "err", err)
 		return
 	}
 
 	_, err = s.ChannelMessageSend(guild.SystemChannelID, fmt.Sprintf("Created a desk for %s", name))
 	if err != nil {
-		fmt.Println("Failed to send created desk message", err)
+		logger.Error("Failed to send created desk message", "guild", event.GuildID, "channel", guild.SystemChannelID, "err", err)
+	}
+}
+
+// Create or archive desks when a member's roles change.
+func guildMemberUpdate(s *discordgo.Session, event *discordgo.GuildMemberUpdate) {
+	if event.User == nil || event.User.Bot {
+		return
+	}
+	if event.BeforeUpdate != nil && slices.Equal(event.BeforeUpdate.Roles, event.Roles) {
+		return
+	}
+
+	logger.Info("guildMemberUpdate", "guild", event.GuildID, "user", event.User.ID)
+
+	maybeDeskCategoryId, ok := guildToDeskCategory.Load(event.GuildID)
+	if !ok {
+		return
+	}
+	deskCategoryId := maybeDeskCategoryId.(string)
+
+	guild, err := s.Guild(event.GuildID)
+	if err != nil {
+		logger.Error("Failed to find guild", "guild", event.GuildID, "err", err)
+		return
+	}
+
+	queueMemberDeskSync(s, guild, deskCategoryId, event.Member)
+}
+
+// Forget desks whose channel was deleted so the owner gets a fresh one.
+func channelDelete(s *discordgo.Session, event *discordgo.ChannelDelete) {
+	if _, ok := deskStore.DeskByChannel(event.GuildID, event.ID); !ok {
+		return
+	}
+	logger.Info("channelDelete", "guild", event.GuildID, "channel", event.ID)
+	if err := deskStore.RemoveDesk(event.GuildID, event.ID); err != nil {
+		logger.Error("Failed to forget deleted desk", "guild", event.GuildID, "channel", event.ID, "err", err)
 	}
 }
 
 // Show and hide user desk voice channels when connected to and disconnected from.
 func voiceStateUpdate(s *discordgo.Session, event *discordgo.VoiceStateUpdate) {
-	fmt.Println("voiceStateUpdate", event.ChannelID)
+	logger.Debug("voiceStateUpdate", "guild", event.GuildID, "channel", event.ChannelID, "user", event.UserID)
+	voiceEvents.Inc()
 	guild, err := s.Guild(event.GuildID)
 	if err != nil {
-		fmt.Println("Failed to find guild", event.GuildID, err)
+		logger.Error("Failed to find guild", "guild", event.GuildID, "err", err)
 		return
 	}
 
 	maybeDeskCategoryId, ok := guildToDeskCategory.Load(event.GuildID)
 	if !ok {
-		fmt.Println("Failed to find deskCategory for guildId", event.GuildID)
+		logger.Warn("Failed to find desk category", "guild", event.GuildID)
 		return
 	}
 	deskCategoryId := maybeDeskCategoryId.(string)
 
+	// Check if user disconnected from a channel. This goes first so a move
+	// straight from one desk to another closes the old seat before the new
+	// one replaces it.
+	handleSourceChanel(event, s, deskCategoryId, guild)
+
 	// Check if user connected to a new channel
 	handleDestinationChannel(event, s, deskCategoryId, guild)
-
-	// Check if user disconnected from a channel
-	handleSourceChanel(event, s, deskCategoryId, guild)
 }
 
 func handleSourceChanel(event *discordgo.VoiceStateUpdate, s *discordgo.Session, deskCategoryId string, guild *discordgo.Guild) {
@@ -265,18 +509,28 @@ func handleSourceChanel(event *discordgo.VoiceStateUpdate, s *discordgo.Session,
 
 	channel, err := s.Channel(event.BeforeUpdate.ChannelID)
 	if err != nil {
-		fmt.Println("Failed to find channel", err)
+		logger.Error("Failed to find channel", "guild", guild.ID, "channel", event.BeforeUpdate.ChannelID, "err", err)
 		return
 	}
 
 	if channel.ParentID != deskCategoryId {
-		fmt.Println("Not a desk channel")
+		logger.Debug("Not a desk channel", "guild", guild.ID, "channel", channel.ID)
 		return
 	}
 
+	if seat, ok := presence.Leave(guild.ID, event.UserID, channel.ID); ok {
+		recordDeskSession(s, guild.ID, event.UserID, channel, seat, time.Now())
+	}
+	scheduleStatusBoardUpdate(s, guild.ID)
+
 	channelMembers := handleDeskDisconnect(guild.ID, channel)
 	if channelMembers == 0 {
+		noteDeskEmpty(s, channel.ID, time.Now())
+		// Visitors let in by a knock who never came in lose access too.
+		showVisitorsOut(s, guild.ID, channel)
 		hideDeskChannel(s, guild, channel)
+	} else {
+		showVisitorsOut(s, guild.ID, channel, event.UserID)
 	}
 }
 
@@ -287,16 +541,23 @@ func handleDestinationChannel(event *discordgo.VoiceStateUpdate, s *discordgo.Se
 
 	channel, err := s.Channel(event.ChannelID)
 	if err != nil {
-		fmt.Println("Failed to find channel", err)
+		logger.Error("Failed to find channel", "guild", guild.ID, "channel", event.ChannelID, "err", err)
 		return
 	}
 
 	if channel.ParentID != deskCategoryId {
-		fmt.Println("Not a desk channel")
+		logger.Debug("Not a desk channel", "guild", guild.ID, "channel", channel.ID)
 		return
 	}
 
 	handleDeskConnect(guild.ID, channel)
+	presence.Join(guild.ID, event.UserID, channel.ID, time.Now())
+	scheduleStatusBoardUpdate(s, guild.ID)
+	noteDeskJoin(s, guild.ID, channel.ID, event.UserID, time.Now())
+
+	if isDeskArchived(guild.ID, channel.ID) {
+		return
+	}
 	showDeskChannel(s, guild, channel)
 }
 
@@ -349,7 +610,7 @@ var prbuddyCommand = &discordgo.ApplicationCommand{
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Name:        "set",
-					Description: "Set a PTO window for a member (dates: YYYY-MM-DD)",
+					Description: "Set a PTO window for a member (dates: YYYY-MM-DD or e.g. \"next monday\")",
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Options: []*discordgo.ApplicationCommandOption{
 						{
@@ -359,19 +620,26 @@ var prbuddyCommand = &discordgo.ApplicationCommand{
 							Required:    true,
 						},
 						{
-							Name:        "leave_on",
-							Description: "First day of absence (YYYY-MM-DD)",
-							Type:        discordgo.ApplicationCommandOptionString,
-							Required:    true,
+							Name:         "leave_on",
+							Description:  "First day of absence (YYYY-MM-DD, \"tomorrow\", \"in 2 weeks\", ...)",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
 						},
 						{
-							Name:        "returns_on",
-							Description: "First day back (YYYY-MM-DD)",
-							Type:        discordgo.ApplicationCommandOptionString,
-							Required:    true,
+							Name:         "returns_on",
+							Description:  "First day back (YYYY-MM-DD, \"next monday\", ...)",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
 						},
 					},
 				},
+				{
+					Name:        "me",
+					Description: "Set your own PTO",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
 				{
 					Name:        "clear",
 					Description: "Clear a member's PTO",
@@ -392,23 +660,143 @@ var prbuddyCommand = &discordgo.ApplicationCommand{
 			Description: "Generate this week's PR buddy pairings now",
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
 		},
+		{
+			Name:        "reroll",
+			Description: "Throw away this week's pairings and draw new ones",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+		{
+			Name:        "reminder",
+			Description: "Ping pairs who haven't checked in mid-week",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "day",
+					Description: "Day to send the reminder at 09:00, or off",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Tuesday", Value: "tuesday"},
+						{Name: "Wednesday", Value: "wednesday"},
+						{Name: "Thursday", Value: "thursday"},
+						{Name: "Friday", Value: "friday"},
+						{Name: "off", Value: "off"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "schedule",
+			Description: "Change when a PR buddy job runs (cron: minute hour day month weekday)",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "job",
+					Description: "The job to reschedule",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "pairings", Value: prbuddy.JobPairings},
+						{Name: "reminder", Value: prbuddy.JobReminder},
+					},
+				},
+				{
+					Name:        "cron",
+					Description: "e.g. \"0 9 * * 1\" for Mondays at 09:00; leave out to restore the default",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		{
+			Name:        "timezone",
+			Description: "Set the time zone PR buddy schedules run in",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "name",
+					Description: "IANA time zone, e.g. Europe/London",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "dms",
+			Description: "Turn your weekly pairing DMs on or off",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "state",
+					Description: "on or off",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "on", Value: "on"},
+						{Name: "off", Value: "off"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "mode",
+			Description: "Choose how desk collaboration affects pairings",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "mode",
+					Description: "random, stretch (rarely talk) or comfort (already collaborate)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "random", Value: string(prbuddy.ModeRandom)},
+						{Name: "stretch", Value: string(prbuddy.ModeStretch)},
+						{Name: "comfort", Value: string(prbuddy.ModeComfort)},
+					},
+				},
+			},
+		},
 	},
 }
 
-func registerCommands(s *discordgo.Session) error {
-	_, err := s.ApplicationCommandCreate(s.State.User.ID, "", prbuddyCommand)
-	return err
-}
-
 func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.Type != discordgo.InteractionApplicationCommand {
-		return
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		interactionLogger(i).Debug("interaction") // on every keystroke
+	} else {
+		interactionLogger(i).Info("interaction")
 	}
-	data := i.ApplicationCommandData()
-	if data.Name != "prbuddy" {
-		return
+	if i.Type == discordgo.InteractionApplicationCommand {
+		commandsHandled.Inc(interactionName(i))
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		switch i.ApplicationCommandData().Name {
+		case "prbuddy":
+			handlePRBuddy(s, i)
+		case "desk":
+			handleDesk(s, i)
+		case "audit":
+			handleAudit(s, i)
+		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		switch i.ApplicationCommandData().Name {
+		case "prbuddy":
+			handlePRBuddyAutocomplete(s, i)
+		}
+	case discordgo.InteractionModalSubmit:
+		switch i.ModalSubmitData().CustomID {
+		case ptoModalID:
+			handlePTOModal(s, i)
+		}
+	case discordgo.InteractionMessageComponent:
+		customID := i.MessageComponentData().CustomID
+		switch {
+		case strings.HasPrefix(customID, deskComponentPrefix):
+			handleDeskComponent(s, i, strings.TrimPrefix(customID, deskComponentPrefix))
+		case strings.HasPrefix(customID, pairingComponentPrefix):
+			handlePairingComponent(s, i, strings.TrimPrefix(customID, pairingComponentPrefix))
+		}
 	}
-	handlePRBuddy(s, i)
 }
 
 func handlePRBuddy(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -424,7 +812,19 @@ func handlePRBuddy(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	case "pto":
 		handlePTO(s, i, opts[0].Options)
 	case "generate":
-		handleGenerate(s, i)
+		handleGenerate(s, i, buddy.Generate)
+	case "reroll":
+		handleGenerate(s, i, buddy.Reroll)
+	case "mode":
+		handleMode(s, i, opts[0].Options)
+	case "dms":
+		handleDMs(s, i, opts[0].Options)
+	case "reminder":
+		handleReminder(s, i, opts[0].Options)
+	case "schedule":
+		handleSchedule(s, i, opts[0].Options)
+	case "timezone":
+		handleTimeZone(s, i, opts[0].Options)
 	default:
 		respond(s, i, "Unknown subcommand.")
 	}
@@ -439,19 +839,28 @@ func handleMember(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*
 	case "add":
 		user := opts[0].Options[0].UserValue(s)
 		name := user.Username
+		before := "not on the team"
+		if m := buddyMember(i.GuildID, user.ID); m != nil {
+			before = m.Name
+		}
 		if err := buddy.AddMember(i.GuildID, user.ID, name); err != nil {
 			respond(s, i, fmt.Sprintf("Failed to add member: %v", err))
 			return
 		}
 		respond(s, i, fmt.Sprintf("Added **%s** to the PR buddy team.", name))
+		auditInteraction(s, i, "PR buddy member added", userMention(user.ID), before, name)
 
 	case "remove":
 		user := opts[0].Options[0].UserValue(s)
+		m := buddyMember(i.GuildID, user.ID)
 		if err := buddy.RemoveMember(i.GuildID, user.ID); err != nil {
 			respond(s, i, fmt.Sprintf("Failed to remove member: %v", err))
 			return
 		}
 		respond(s, i, fmt.Sprintf("Removed **%s** from the PR buddy team.", user.Username))
+		if m != nil {
+			auditInteraction(s, i, "PR buddy member removed", userMention(user.ID), m.Name, "not on the team")
+		}
 
 	default:
 		respond(s, i, "Unknown member subcommand.")
@@ -470,88 +879,463 @@ func handlePTO(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*dis
 		leaveOnStr := subOpts[1].StringValue()
 		returnsOnStr := subOpts[2].StringValue()
 
-		leaveOn, err := time.ParseInLocation("2006-01-02", leaveOnStr, time.Local)
-		if err != nil {
-			respond(s, i, fmt.Sprintf("Invalid leave_on date %q — use YYYY-MM-DD.", leaveOnStr))
-			return
-		}
-		returnsOn, err := time.ParseInLocation("2006-01-02", returnsOnStr, time.Local)
+		leaveOn, returnsOn, err := parsePTODates(leaveOnStr, returnsOnStr, time.Now())
 		if err != nil {
-			respond(s, i, fmt.Sprintf("Invalid returns_on date %q — use YYYY-MM-DD.", returnsOnStr))
+			respond(s, i, err.Error())
 			return
 		}
 
+		before := formatPTO(buddyMember(i.GuildID, user.ID))
 		if err := buddy.SetPTO(i.GuildID, user.ID, leaveOn, returnsOn); err != nil {
 			respond(s, i, fmt.Sprintf("Failed to set PTO: %v", err))
 			return
 		}
-		respond(s, i, fmt.Sprintf("PTO set for **%s**: away %s → back %s.", user.Username, leaveOnStr, returnsOnStr))
+		respond(s, i, fmt.Sprintf("PTO set for **%s**: away %s → back %s.", user.Username,
+			leaveOn.Format(prbuddy.DateLayout), returnsOn.Format(prbuddy.DateLayout)))
+		auditInteraction(s, i, "PR buddy PTO set", userMention(user.ID), before, formatPTO(buddyMember(i.GuildID, user.ID)))
 
 	case "clear":
 		user := opts[0].Options[0].UserValue(s)
+		before := formatPTO(buddyMember(i.GuildID, user.ID))
 		if err := buddy.ClearPTO(i.GuildID, user.ID); err != nil {
 			respond(s, i, fmt.Sprintf("Failed to clear PTO: %v", err))
 			return
 		}
 		respond(s, i, fmt.Sprintf("PTO cleared for **%s**.", user.Username))
+		auditInteraction(s, i, "PR buddy PTO cleared", userMention(user.ID), before, "none")
+
+	case "me":
+		showPTOModal(s, i)
 
 	default:
 		respond(s, i, "Unknown pto subcommand.")
 	}
 }
 
-func handleGenerate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	result := buddy.Generate(i.GuildID, time.Now())
-	msg := formatPairings(result)
+// parsePTODates reads a PTO window typed by a member, rejecting one that is
+// already over. Errors are worded for the member.
+func parsePTODates(leaveOnStr, returnsOnStr string, now time.Time) (leaveOn, returnsOn time.Time, err error) {
+	leaveOn, err = prbuddy.ParseDate(leaveOnStr, now)
+	if err != nil {
+		return leaveOn, returnsOn, fmt.Errorf("Invalid leave_on: %v.", err)
+	}
+	returnsOn, err = prbuddy.ParseDate(returnsOnStr, now)
+	if err != nil {
+		return leaveOn, returnsOn, fmt.Errorf("Invalid returns_on: %v.", err)
+	}
+	// The last day away is the day before returns_on, so PTO returning
+	// today or earlier is already over.
+	if today, _ := prbuddy.ParseDate("today", now); !returnsOn.After(today) {
+		return leaveOn, returnsOn, fmt.Errorf("That PTO is entirely in the past.")
+	}
+	return leaveOn, returnsOn, nil
+}
+
+// ptoModalID is the custom ID of the /prbuddy pto me modal.
+const ptoModalID = "prbuddy:pto-me"
+
+// showPTOModal asks the invoking member for their own leave window.
+func showPTOModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	input := func(id, label, placeholder string, required bool, style discordgo.TextInputStyle) discordgo.MessageComponent {
+		return discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:    id,
+				Label:       label,
+				Style:       style,
+				Placeholder: placeholder,
+				Required:    required,
+				MaxLength:   200,
+			},
+		}}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: ptoModalID,
+			Title:    "Set your PTO",
+			Components: []discordgo.MessageComponent{
+				input("leave_on", "First day away", "YYYY-MM-DD, tomorrow, next friday, ...", true, discordgo.TextInputShort),
+				input("returns_on", "First day back", "YYYY-MM-DD, next monday, in 2 weeks, ...", true, discordgo.TextInputShort),
+				input("note", "Note (optional)", "Where you'll be or who covers for you", false, discordgo.TextInputParagraph),
+			},
+		},
+	})
+	if err != nil {
+		interactionLogger(i).Error("Failed to show PTO modal", "err", err)
+	}
+}
+
+// handlePTOModal stores the PTO a member entered in the /prbuddy pto me modal
+// and lists the pairing weeks they'll miss.
+func handlePTOModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Member == nil {
+		respond(s, i, "PR buddy commands only work inside a server.")
+		return
+	}
+	values := make(map[string]string)
+	for _, row := range i.ModalSubmitData().Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok {
+				values[input.CustomID] = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+
+	leaveOn, returnsOn, err := parsePTODates(values["leave_on"], values["returns_on"], time.Now())
+	if err != nil {
+		respond(s, i, err.Error())
+		return
+	}
+	before := formatPTO(buddyMember(i.GuildID, i.Member.User.ID))
+	if err := buddy.SetPTOWithNote(i.GuildID, i.Member.User.ID, leaveOn, returnsOn, values["note"]); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set PTO: %v", err))
+		return
+	}
+
+	msg := fmt.Sprintf("PTO set: away %s → back %s.",
+		leaveOn.Format(prbuddy.DateLayout), returnsOn.Format(prbuddy.DateLayout))
+	weeks := prbuddy.MissedWeeks(leaveOn, returnsOn)
+	if len(weeks) == 0 {
+		msg += "\nYou won't miss any PR buddy pairings."
+	} else {
+		msg += "\nYou'll sit out PR buddy pairings for the weeks of:"
+		for _, week := range weeks {
+			msg += "\n• " + week.Format("Jan 2, 2006")
+		}
+	}
 	respond(s, i, msg)
+	auditInteraction(s, i, "PR buddy PTO set", userMention(i.Member.User.ID), before,
+		formatPTO(buddyMember(i.GuildID, i.Member.User.ID)))
+}
+
+// handlePRBuddyAutocomplete suggests dates for /prbuddy pto set. returns_on
+// suggestions start the day after leave_on when it has already been filled in.
+func handlePRBuddyAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	opts := i.ApplicationCommandData().Options
+	if len(opts) == 0 || opts[0].Name != "pto" || len(opts[0].Options) == 0 || opts[0].Options[0].Name != "set" {
+		return
+	}
+
+	now := time.Now()
+	notBefore := now
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, opt := range opts[0].Options[0].Options {
+		if opt.Focused {
+			focused = opt
+		} else if opt.Name == "leave_on" {
+			if leaveOn, err := prbuddy.ParseDate(opt.StringValue(), now); err == nil {
+				notBefore = leaveOn.AddDate(0, 0, 1)
+			}
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	suggestions := prbuddy.SuggestDates(focused.StringValue(), now, notBefore, 25)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(suggestions))
+	for idx, suggestion := range suggestions {
+		choices[idx] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  suggestion.Label,
+			Value: suggestion.Date.Format(prbuddy.DateLayout),
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		interactionLogger(i).Error("Failed to send PTO date suggestions", "err", err)
+	}
+}
+
+func handleMode(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	mode := prbuddy.PairingMode(opts[0].StringValue())
+	before := buddy.PairingMode(i.GuildID)
+	if err := buddy.SetPairingMode(i.GuildID, mode); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set pairing mode: %v", err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("PR buddy pairing mode set to **%s**.", mode))
+	auditInteraction(s, i, "PR buddy pairing mode set", "", string(before), string(mode))
+}
+
+func handleReminder(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	choice := opts[0].StringValue()
+	before := scheduleDescription(i.GuildID, prbuddy.JobReminder)
+	if choice == "off" {
+		if err := buddy.ClearReminder(i.GuildID); err != nil {
+			respond(s, i, fmt.Sprintf("Failed to turn off reminders: %v", err))
+			return
+		}
+		respond(s, i, "Mid-week PR buddy reminders are off.")
+		auditInteraction(s, i, "PR buddy reminder schedule set", "", before, "off")
+		return
+	}
+
+	day, ok := map[string]time.Weekday{
+		"tuesday":   time.Tuesday,
+		"wednesday": time.Wednesday,
+		"thursday":  time.Thursday,
+		"friday":    time.Friday,
+	}[choice]
+	if !ok {
+		respond(s, i, fmt.Sprintf("Unknown reminder day %q.", choice))
+		return
+	}
+	if err := buddy.SetReminderDay(i.GuildID, day); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set reminder day: %v", err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Pairs who haven't checked in will be pinged every %s at 09:00.", day))
+	auditInteraction(s, i, "PR buddy reminder schedule set", "", before, scheduleDescription(i.GuildID, prbuddy.JobReminder))
+}
+
+func handleSchedule(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	job := opts[0].StringValue()
+	spec := ""
+	if len(opts) > 1 {
+		spec = strings.TrimSpace(opts[1].StringValue())
+	}
+
+	before := scheduleDescription(i.GuildID, job)
+	if err := buddy.SetSchedule(i.GuildID, job, spec); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set schedule: %v", err))
+		return
+	}
+	if next, ok := buddy.NextRun(i.GuildID, job); ok {
+		respond(s, i, fmt.Sprintf("The %s job next runs <t:%d:F>.", job, next.Unix()))
+	} else {
+		respond(s, i, fmt.Sprintf("The %s job is off.", job))
+	}
+	auditInteraction(s, i, fmt.Sprintf("PR buddy %s schedule set", job), "", before, scheduleDescription(i.GuildID, job))
+}
+
+func handleTimeZone(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	name := strings.TrimSpace(opts[0].StringValue())
+	before := buddy.TimeZone(i.GuildID)
+	if err := buddy.SetTimeZone(i.GuildID, name); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to set time zone: %v", err))
+		return
+	}
+	respond(s, i, fmt.Sprintf("PR buddy schedules now run in **%s**.", name))
+	auditInteraction(s, i, "PR buddy time zone set", "", cmp.Or(before, "default"), cmp.Or(name, "default"))
+}
+
+// handleGenerate posts this week's pairings from generate, which is either
+// buddy.Generate (the week as it stands if it already has pairings, swaps
+// and check-ins included) or buddy.Reroll.
+func handleGenerate(s *discordgo.Session, i *discordgo.InteractionCreate, generate func(guildID string, t time.Time) prbuddy.Result) {
+	before := currentPairs(i.GuildID)
+	result := generate(i.GuildID, time.Now())
+	pairingRuns.Inc(i.GuildID, "command")
+	respond(s, i, announce.Text(result))
+	action := "Pairings generated"
+	if interactionName(i) == "prbuddy reroll" {
+		action = "Pairings rerolled"
+	}
+	auditInteraction(s, i, action, "", before, formatPairs(result))
 	// Also post to #general so the team sees it.
 	postPairings(s, i.GuildID, result)
+
+	if !cfg.Features.PairingDMs {
+		return
+	}
+	// DMs can take a while, so report failures in a follow-up, and in the
+	// audit log as the scheduled run does.
+	if failed := dmPairings(s, i.GuildID, result); len(failed) > 0 {
+		auditDMFailures(s, i.GuildID, failed)
+		_, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+			Content: formatDMFailures(failed),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		if err != nil {
+			interactionLogger(i).Error("prbuddy: failed to report DM failures", "err", err)
+		}
+	}
 }
 
 // ---------------------------------------------------------------------------
 // Pairing output helpers
 // ---------------------------------------------------------------------------
 
-// postPairings finds the guild's #general channel and posts the pairing result.
-func postPairings(s *discordgo.Session, guildID string, result prbuddy.Result) {
+// generalChannelID finds the guild's pairings channel, #general unless the
+// config names another, where PR buddy posts.
+func generalChannelID(s *discordgo.Session, guildID string) (string, bool) {
 	channels, err := s.GuildChannels(guildID)
 	if err != nil {
-		fmt.Println("prbuddy: failed to fetch channels:", err)
-		return
+		logger.Error("prbuddy: failed to fetch channels", "guild", guildID, "err", err)
+		return "", false
 	}
-	var generalID string
 	for _, ch := range channels {
-		if ch.Type == discordgo.ChannelTypeGuildText && strings.ToLower(ch.Name) == "general" {
-			generalID = ch.ID
-			break
+		if ch.Type == discordgo.ChannelTypeGuildText && strings.EqualFold(ch.Name, cfg.Channels.Pairings) {
+			return ch.ID, true
 		}
 	}
-	if generalID == "" {
-		fmt.Println("prbuddy: no #general channel found in guild", guildID)
+	logger.Warn("prbuddy: no pairings channel found", "guild", guildID, "name", cfg.Channels.Pairings)
+	return "", false
+}
+
+// postPairings finds the guild's #general channel and posts the pairing result.
+func postPairings(s *discordgo.Session, guildID string, result prbuddy.Result) {
+	generalID, ok := generalChannelID(s, guildID)
+	if !ok {
+		return
+	}
+	// Mentions in embeds don't notify anyone, so the members are pinged in the
+	// message content, and only them.
+	allowed := &discordgo.MessageAllowedMentions{Users: announce.Mentioned(result)}
+	_, err := s.ChannelMessageSendComplex(generalID, &discordgo.MessageSend{
+		Content:         announce.Mentions(result),
+		Embeds:          announce.Embeds(result, pairingOptions(s, guildID)),
+		Components:      pairingComponents(result),
+		AllowedMentions: allowed,
+	})
+	if missingPermissions(err) {
+		// Most likely missing Embed Links; fall back to plain text.
+		logger.Warn("prbuddy: failed to post pairing embeds, sending text", "guild", guildID, "channel", generalID, "err", err)
+		_, err = s.ChannelMessageSendComplex(generalID, &discordgo.MessageSend{
+			Content:         announce.Text(result),
+			Components:      pairingComponents(result),
+			AllowedMentions: allowed,
+		})
+	}
+	if err != nil {
+		logger.Error("prbuddy: failed to post pairings", "guild", guildID, "channel", generalID, "err", err)
+	}
+}
+
+// missingPermissions reports whether a Discord request was refused for lack
+// of permissions, as opposed to failing in a way that may have gone through.
+func missingPermissions(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	if restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeMissingPermissions {
+		return true
+	}
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusForbidden
+}
+
+// postReminder pings the members in #general who haven't checked in on their
+// pairing.
+func postReminder(s *discordgo.Session, guildID string, reminder prbuddy.Reminder) {
+	generalID, ok := generalChannelID(s, guildID)
+	if !ok {
 		return
 	}
-	msg := formatPairings(result)
-	if _, err := s.ChannelMessageSend(generalID, msg); err != nil {
-		fmt.Println("prbuddy: failed to post pairings:", err)
+	_, err := s.ChannelMessageSendComplex(generalID, &discordgo.MessageSend{
+		Content:         announce.ReminderText(reminder),
+		AllowedMentions: &discordgo.MessageAllowedMentions{Users: reminder.Pending},
+	})
+	if err != nil {
+		logger.Error("prbuddy: failed to post reminder", "guild", guildID, "channel", generalID, "err", err)
 	}
 }
 
-// formatPairings renders a Result as a human-readable Discord message.
-func formatPairings(result prbuddy.Result) string {
+// pairingOptions returns what the announcer needs beyond the Result: members'
+// display names and avatars from the state cache and the next scheduled run.
+func pairingOptions(s *discordgo.Session, guildID string) announce.Options {
+	nextRun, _ := buddy.NextRun(guildID, prbuddy.JobPairings)
+	return announce.Options{
+		Lookup: func(userID string) announce.Profile {
+			member, err := s.State.Member(guildID, userID)
+			if err != nil {
+				return announce.Profile{}
+			}
+			return announce.Profile{Name: member.DisplayName(), AvatarURL: member.AvatarURL("64")}
+		},
+		NextRun: nextRun,
+	}
+}
+
+// pairingComponentPrefix namespaces the custom IDs of the pairing message's
+// buttons, which look like "prbuddy:<action>:<week>".
+const pairingComponentPrefix = "prbuddy:"
+
+// pairingComponents returns the buttons paired members use to adjust their own
+// pairing, or nil when there are no pairs to adjust.
+func pairingComponents(result prbuddy.Result) []discordgo.MessageComponent {
 	if len(result.Pairs) == 0 {
-		return fmt.Sprintf("No PR buddy pairings this week (%s) — not enough available developers.",
-			result.Week.Format("Jan 2, 2006"))
+		return nil
+	}
+	week := result.Week.Format(prbuddy.DateLayout)
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Got it", Style: discordgo.PrimaryButton, CustomID: pairingComponentPrefix + "ack:" + week},
+			discordgo.Button{Label: "Swap me", Style: discordgo.SecondaryButton, CustomID: pairingComponentPrefix + "swap:" + week},
+			discordgo.Button{Label: "I'm out this week", Style: discordgo.SecondaryButton, CustomID: pairingComponentPrefix + "out:" + week},
+			discordgo.Button{Label: "Done reviewing", Style: discordgo.SuccessButton, CustomID: pairingComponentPrefix + "done:" + week},
+		}},
 	}
+}
 
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("**PR Buddy pairings — week of %s**\n", result.Week.Format("Jan 2, 2006")))
-	for idx, p := range result.Pairs {
-		sb.WriteString(fmt.Sprintf("%d. <@%s> ↔ <@%s>\n", idx+1, p.A.UserID, p.B.UserID))
+// handlePairingComponent applies a pairing button press to the stored week and
+// edits the pairing message to match.
+func handlePairingComponent(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	action, weekStr, _ := strings.Cut(customID, ":")
+	week, err := time.Parse(prbuddy.DateLayout, weekStr)
+	if err != nil {
+		respond(s, i, "That button is broken.")
+		return
+	}
+	userID := i.Member.User.ID
+	before := currentPairs(i.GuildID)
+
+	var result prbuddy.Result
+	switch action {
+	case "ack":
+		result, err = buddy.MarkAcked(i.GuildID, userID, week)
+	case "swap":
+		result, err = buddy.Swap(i.GuildID, userID, week)
+	case "out":
+		result, err = buddy.MarkOut(i.GuildID, userID, week)
+	case "done":
+		result, err = buddy.MarkDone(i.GuildID, userID, week)
+	default:
+		respond(s, i, "That button is broken.")
+		return
 	}
-	if result.SittingOut != nil {
-		sb.WriteString(fmt.Sprintf("\n_<@%s> is sitting out this week._", result.SittingOut.UserID))
+	if err != nil {
+		respond(s, i, fmt.Sprintf("Couldn't update the pairings: %v.", err))
+		return
+	}
+
+	components := pairingComponents(result)
+	if components == nil {
+		// Clear the buttons once nobody is left to pair.
+		components = []discordgo.MessageComponent{}
+	}
+	data := &discordgo.InteractionResponseData{Components: components}
+	// Keep the message in whichever form it was posted.
+	if len(i.Message.Embeds) > 0 {
+		data.Content = announce.Mentions(result)
+		data.Embeds = announce.Embeds(result, pairingOptions(s, i.GuildID))
+	} else {
+		data.Content = announce.Text(result)
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+	if err != nil {
+		interactionLogger(i).Error("prbuddy: failed to update pairings message", "err", err)
+	}
+
+	// Acknowledging and finishing reviews leave the pairs as they are.
+	switch action {
+	case "swap":
+		auditInteraction(s, i, "Pairing swapped", userMention(userID), before, formatPairs(result))
+	case "out":
+		auditInteraction(s, i, "Out for the week", userMention(userID), before, formatPairs(result))
 	}
-	return sb.String()
 }
 
 // respond sends an ephemeral interaction reply.
@@ -564,17 +1348,17 @@ func respond(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
 		},
 	})
 	if err != nil {
-		fmt.Println("Failed to respond to interaction:", err)
+		interactionLogger(i).Error("Failed to respond to interaction", "err", err)
 	}
 }
 
 // ---------------------------------------------------------------------------
-// Desk channel helpers (unchanged)
+// Desk channel helpers
 // ---------------------------------------------------------------------------
 
 // If any user enters, make the desk visible.
 func handleDeskConnect(guildID string, channel *discordgo.Channel) int {
-	fmt.Println("User connected to desk", channel.ID)
+	logger.Debug("User connected to desk", "guild", guildID, "channel", channel.ID)
 
 	guildChannelMembersMutex.Lock()
 	channelMembers := guildChannelMembers[guildID][channel.ID]
@@ -585,38 +1369,102 @@ func handleDeskConnect(guildID string, channel *discordgo.Channel) int {
 	return channelMembers
 }
 
-// Make desk visible to @everyone
+// Make desk visible to @everyone, as far as the owner's desk mode allows: DND
+// desks stay hidden, and knock desks can be seen but not joined uninvited.
+// Desks with session notes hide their message history from non-guests, and
+// locked desks are left alone. The edit is queued, replacing any show or hide
+// of the same desk that hasn't run yet.
 func showDeskChannel(s *discordgo.Session, guild *discordgo.Guild, channel *discordgo.Channel) {
-	for _, permission := range channel.PermissionOverwrites {
-		if permission.Type == discordgo.PermissionOverwriteTypeRole && permission.ID == guild.ID {
-			if permission.Allow&discordgo.PermissionViewChannel != 0 && permission.Deny&discordgo.PermissionViewChannel == 0 {
-				return
-			}
-			break
-		}
+	queueDeskEdit(guild.ID, visibilityEditKey(channel), func() error {
+		return applyShowDesk(s, guild, currentChannel(s, channel))
+	})
+}
+
+func applyShowDesk(s *discordgo.Session, guild *discordgo.Guild, channel *discordgo.Channel) error {
+	if deskLocked(guild.ID, channel.ID) {
+		return nil
+	}
+	mode := deskMode(guild.ID, channel.ID)
+	if mode == desks.ModeDND {
+		return applyHideDesk(s, guild, channel)
 	}
 
-	fmt.Println("Enabling desk visibility", channel.ID)
-	_, err := s.ChannelEdit(
-		channel.ID, &discordgo.ChannelEdit{
-			PermissionOverwrites: append(
+	everyone := permissionOverwrite(channel, guild.ID, discordgo.PermissionOverwriteTypeRole)
+	allow := everyone.Allow | discordgo.PermissionViewChannel
+	deny := everyone.Deny &^ discordgo.PermissionViewChannel
+	if mode == desks.ModeKnock {
+		deny |= discordgo.PermissionVoiceConnect
+	} else {
+		deny &^= discordgo.PermissionVoiceConnect
+	}
+	// Visitors can follow along live, but past session notes stay private to
+	// the owner and their guests.
+	if notesEnabled(guild.ID, channel.ID) {
+		deny |= discordgo.PermissionReadMessageHistory
+	} else {
+		deny &^= discordgo.PermissionReadMessageHistory
+	}
+	if allow == everyone.Allow && deny == everyone.Deny {
+		return nil
+	}
+
+	logger.Info("Enabling desk visibility", "guild", guild.ID, "channel", channel.ID, "mode", mode)
+	_, err := editChannel(
+		s, channel.ID, &discordgo.ChannelEdit{
+			PermissionOverwrites: upsertPermissionOverwrite(
 				channel.PermissionOverwrites,
 				&discordgo.PermissionOverwrite{
 					ID:    guild.ID,
 					Type:  discordgo.PermissionOverwriteTypeRole,
-					Allow: discordgo.PermissionViewChannel,
+					Allow: allow,
+					Deny:  deny,
 				},
 			),
 		},
 	)
 	if err != nil {
-		fmt.Println("Failed to update channel", err)
+		return err
 	}
+	deskVisibilityChanges.Inc("show")
+
+	if mode == desks.ModeKnock && everyone.Allow&discordgo.PermissionViewChannel == 0 {
+		postKnockPrompt(s, guild.ID, channel)
+	}
+	return nil
+}
+
+// visibilityEditKey is the queue key shared by a desk's show and hide edits,
+// so only the latest of them runs.
+func visibilityEditKey(channel *discordgo.Channel) string {
+	return "visibility:" + channel.ID
+}
+
+// deskMode returns the visibility mode of a desk, defaulting to open for
+// channels the desk store doesn't know about.
+func deskMode(guildID, channelID string) desks.Mode {
+	desk, ok := deskStore.DeskByChannel(guildID, channelID)
+	if !ok {
+		return desks.ModeOpen
+	}
+	return desk.EffectiveMode()
+}
+
+// deskLocked reports whether the owner has locked the desk's visibility.
+func deskLocked(guildID, channelID string) bool {
+	desk, ok := deskStore.DeskByChannel(guildID, channelID)
+	return ok && desk.Locked
+}
+
+// deskOccupied reports whether anyone is currently connected to a desk.
+func deskOccupied(guildID, channelID string) bool {
+	guildChannelMembersMutex.Lock()
+	defer guildChannelMembersMutex.Unlock()
+	return guildChannelMembers[guildID][channelID] != 0
 }
 
 // If the last user leaves, hide the desk.
 func handleDeskDisconnect(guildID string, channel *discordgo.Channel) int {
-	fmt.Println("User disconnected from desk", channel.ID)
+	logger.Debug("User disconnected from desk", "guild", guildID, "channel", channel.ID)
 
 	guildChannelMembersMutex.Lock()
 	channelMembers := guildChannelMembers[guildID][channel.ID]
@@ -627,35 +1475,52 @@ func handleDeskDisconnect(guildID string, channel *discordgo.Channel) int {
 	return channelMembers
 }
 
-// Hide the desk from @everyone except the owner
+// Hide the desk from @everyone except the owner, unless its visibility is
+// locked. Like showDeskChannel, the edit is queued.
 func hideDeskChannel(s *discordgo.Session, guild *discordgo.Guild, channel *discordgo.Channel) {
-	for _, permission := range channel.PermissionOverwrites {
-		if permission.Type == discordgo.PermissionOverwriteTypeRole && permission.ID == guild.ID {
-			if permission.Allow&discordgo.PermissionViewChannel == 0 && permission.Deny&discordgo.PermissionViewChannel != 0 {
-				return
-			}
-			break
-		}
+	queueDeskEdit(guild.ID, visibilityEditKey(channel), func() error {
+		return applyHideDesk(s, guild, currentChannel(s, channel))
+	})
+}
+
+func applyHideDesk(s *discordgo.Session, guild *discordgo.Guild, channel *discordgo.Channel) error {
+	if deskLocked(guild.ID, channel.ID) {
+		return nil
 	}
 
-	fmt.Println("Disabling desk visibility", channel.ID)
-	_, err := s.ChannelEdit(channel.ID, &discordgo.ChannelEdit{
-		PermissionOverwrites: append(
+	// A hidden desk needs no knock or session notes restrictions, so clear
+	// the ones showDeskChannel may have set rather than leave them behind.
+	everyone := permissionOverwrite(channel, guild.ID, discordgo.PermissionOverwriteTypeRole)
+	allow := everyone.Allow &^ discordgo.PermissionViewChannel
+	deny := (everyone.Deny | discordgo.PermissionViewChannel) &^ (discordgo.PermissionVoiceConnect | discordgo.PermissionReadMessageHistory)
+	if allow == everyone.Allow && deny == everyone.Deny {
+		return nil
+	}
+
+	logger.Info("Disabling desk visibility", "guild", guild.ID, "channel", channel.ID)
+	_, err := editChannel(s, channel.ID, &discordgo.ChannelEdit{
+		PermissionOverwrites: upsertPermissionOverwrite(
 			channel.PermissionOverwrites,
 			&discordgo.PermissionOverwrite{
-				ID:   guild.ID,
-				Type: discordgo.PermissionOverwriteTypeRole,
-				Deny: discordgo.PermissionViewChannel,
+				ID:    guild.ID,
+				Type:  discordgo.PermissionOverwriteTypeRole,
+				Allow: allow,
+				Deny:  deny,
 			},
 		),
 	})
 	if err != nil {
-		fmt.Println("Failed to update channel", err)
+		return err
+	}
+	deskVisibilityChanges.Inc("hide")
+	if desk, ok := deskStore.DeskByChannel(guild.ID, channel.ID); ok {
+		removeKnockPrompt(s, guild.ID, desk)
 	}
+	return nil
 }
 
 func createDeskChannel(s *discordgo.Session, guildID string, userID string, name string, deskCategoryId string) error {
-	_, err := s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
+	channel, err := s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
 		Name: name,
 		Type: discordgo.ChannelTypeGuildVoice,
 		PermissionOverwrites: []*discordgo.PermissionOverwrite{
@@ -678,18 +1543,162 @@ func createDeskChannel(s *discordgo.Session, guildID string, userID string, name
 		ParentID: deskCategoryId,
 		Position: 0,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// Like editChannel, let queued syncs see the new desk before its create
+	// event arrives.
+	s.State.ChannelAdd(channel)
+	return deskStore.RecordDesk(guildID, channel.ID, userID, time.Now())
+}
+
+// isDeskArchived reports whether a desk has been hidden from its owner by
+// applyArchiveDesk.
+func isDeskArchived(guildID, channelID string) bool {
+	desk, ok := deskStore.DeskByChannel(guildID, channelID)
+	return ok && desk.Archived
+}
+
+// archiveDeskChannel queues applyArchiveDesk and waits for it.
+func archiveDeskChannel(s *discordgo.Session, channel *discordgo.Channel, ownerID string) error {
+	return runDeskEdit(channel.GuildID, archiveEditKey(channel), func() error {
+		_, err := applyArchiveDesk(s, currentChannel(s, channel), ownerID)
+		return err
+	})
+}
+
+// archiveEditKey is the queue key shared by a desk's archive and restore
+// edits, so only the latest of them runs.
+func archiveEditKey(channel *discordgo.Channel) string {
+	return "archive:" + channel.ID
+}
+
+// Archive a desk by hiding it from everyone, owner included. The owner keeps
+// ManageChannels so the overwrite heuristic in getChannelOwner still agrees
+// with the desk store, and the channel's name, limits and other settings are
+// left untouched for when they opt back in.
+func applyArchiveDesk(s *discordgo.Session, channel *discordgo.Channel, ownerID string) (*discordgo.Channel, error) {
+	owner := permissionOverwrite(channel, ownerID, discordgo.PermissionOverwriteTypeMember)
+	overwrites := upsertPermissionOverwrite(channel.PermissionOverwrites, &discordgo.PermissionOverwrite{
+		ID:    ownerID,
+		Type:  discordgo.PermissionOverwriteTypeMember,
+		Allow: (owner.Allow | discordgo.PermissionManageChannels) &^ discordgo.PermissionViewChannel,
+		Deny:  owner.Deny | discordgo.PermissionViewChannel,
+	})
+	overwrites = upsertPermissionOverwrite(overwrites, &discordgo.PermissionOverwrite{
+		ID:   channel.GuildID,
+		Type: discordgo.PermissionOverwriteTypeRole,
+		Deny: discordgo.PermissionViewChannel,
+	})
+	archived, err := editChannel(s, channel.ID, &discordgo.ChannelEdit{PermissionOverwrites: overwrites})
+	if err != nil {
+		return nil, err
+	}
+	return archived, deskStore.SetArchived(channel.GuildID, channel.ID, true)
+}
+
+// Undo applyArchiveDesk, giving the owner their desk permissions back.
+func applyRestoreDesk(s *discordgo.Session, channel *discordgo.Channel, ownerID string) (*discordgo.Channel, error) {
+	owner := permissionOverwrite(channel, ownerID, discordgo.PermissionOverwriteTypeMember)
+	overwrites := upsertPermissionOverwrite(channel.PermissionOverwrites, &discordgo.PermissionOverwrite{
+		ID:    ownerID,
+		Type:  discordgo.PermissionOverwriteTypeMember,
+		Allow: owner.Allow | USER_DESK_PERMISSIONS,
+		Deny:  owner.Deny &^ discordgo.PermissionViewChannel,
+	})
+	restored, err := editChannel(s, channel.ID, &discordgo.ChannelEdit{PermissionOverwrites: overwrites})
+	if err != nil {
+		return nil, err
+	}
+	return restored, deskStore.SetArchived(channel.GuildID, channel.ID, false)
+}
+
+// permissionOverwrite returns the channel's overwrite for a member or role, or
+// an empty overwrite if there is none.
+func permissionOverwrite(channel *discordgo.Channel, id string, overwriteType discordgo.PermissionOverwriteType) discordgo.PermissionOverwrite {
+	for _, permission := range channel.PermissionOverwrites {
+		if permission.Type == overwriteType && permission.ID == id {
+			return *permission
+		}
+	}
+	return discordgo.PermissionOverwrite{ID: id, Type: overwriteType}
 }
 
-func findUserDeskChannel(channels []*discordgo.Channel, deskCategoryId any, userID string, botID string) *discordgo.Channel {
+// upsertPermissionOverwrite returns a copy of overwrites with any entry for the
+// same ID replaced by overwrite, or with overwrite appended if there was none.
+func upsertPermissionOverwrite(overwrites []*discordgo.PermissionOverwrite, overwrite *discordgo.PermissionOverwrite) []*discordgo.PermissionOverwrite {
+	return append(removePermissionOverwrite(overwrites, overwrite.ID), overwrite)
+}
+
+// removePermissionOverwrite returns a copy of overwrites without any entry for id.
+func removePermissionOverwrite(overwrites []*discordgo.PermissionOverwrite, id string) []*discordgo.PermissionOverwrite {
+	out := make([]*discordgo.PermissionOverwrite, 0, len(overwrites)+1)
+	for _, existing := range overwrites {
+		if existing.ID != id {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+// findUserDeskChannel returns the user's desk among channels, or nil. The desk
+// store is authoritative; desks created before the store existed are found by
+// the owner overwrite heuristic and recorded so later lookups use the store.
+func findUserDeskChannel(guildID string, channels []*discordgo.Channel, deskCategoryId any, userID string, botID string) *discordgo.Channel {
+	if desk, ok := deskStore.DeskByOwner(guildID, userID); ok {
+		for _, channel := range channels {
+			if channel.ID == desk.ChannelID {
+				return channel
+			}
+		}
+		// The recorded channel is gone, most likely deleted while we were offline.
+		if err := deskStore.RemoveDesk(guildID, desk.ChannelID); err != nil {
+			logger.Error("Failed to forget deleted desk", "guild", guildID, "channel", desk.ChannelID, "err", err)
+		}
+	}
+
 	for _, channel := range channels {
-		if channel.ParentID == deskCategoryId && userID == getChannelOwner(channel, botID) {
+		if channel.ParentID != deskCategoryId || userID != getChannelOwner(channel, botID) {
+			continue
+		}
+		if _, owned := deskStore.DeskByChannel(guildID, channel.ID); owned {
+			continue
+		}
+		createdAt, err := discordgo.SnowflakeTimestamp(channel.ID)
+		if err != nil {
+			createdAt = time.Now()
+		}
+		logger.Info("Migrating desk channel to the desk store", "guild", guildID, "channel", channel.ID, "user", userID)
+		if err := deskStore.RecordDesk(guildID, channel.ID, userID, createdAt); err != nil {
+			logger.Error("Failed to record desk", "guild", guildID, "channel", channel.ID, "err", err)
 			return channel
 		}
+		// Desks archived before the store existed were only marked by the
+		// owner's overwrite hiding the channel from them.
+		owner := permissionOverwrite(channel, userID, discordgo.PermissionOverwriteTypeMember)
+		if owner.Deny&discordgo.PermissionViewChannel != 0 {
+			if err := deskStore.SetArchived(guildID, channel.ID, true); err != nil {
+				logger.Error("Failed to record archived desk", "guild", guildID, "channel", channel.ID, "err", err)
+			}
+		}
+		return channel
 	}
 	return nil
 }
 
+// deskOwner returns the user ID that owns a desk channel according to the
+// desk store, or "" if it is not a desk.
+func deskOwner(guildID, channelID string) string {
+	desk, ok := deskStore.DeskByChannel(guildID, channelID)
+	if !ok {
+		return ""
+	}
+	return desk.OwnerID
+}
+
+// getChannelOwner guesses a desk's owner from the member overwrite granting
+// ManageChannels. It is only used by findUserDeskChannel to migrate desks
+// into the desk store; everything else asks the store via deskOwner.
 func getChannelOwner(channel *discordgo.Channel, botID string) string {
 	for _, permission := range channel.PermissionOverwrites {
 		if permission.Type == discordgo.PermissionOverwriteTypeMember &&
@@ -701,45 +1710,97 @@ func getChannelOwner(channel *discordgo.Channel, botID string) string {
 	return ""
 }
 
+// resetDeskPermissions queues applyResetDeskPermissions and waits for it.
 func resetDeskPermissions(s *discordgo.Session, channel *discordgo.Channel, userId string) error {
-	var userPermissions int64
-	var botPermissions int64
+	return runDeskEdit(channel.GuildID, "permissions:"+channel.ID, func() error {
+		return applyResetDeskPermissions(s, currentChannel(s, channel), userId)
+	})
+}
 
-	for _, permission := range channel.PermissionOverwrites {
-		if permission.Type == discordgo.PermissionOverwriteTypeMember {
-			if permission.ID == userId {
-				userPermissions = permission.Allow
-			}
-			if permission.ID == s.State.User.ID {
-				botPermissions = permission.Allow
+// applyResetDeskPermissions makes sure the owner, the bot and every guest on
+// the desk's guest list have the access they need, including the text chat
+// permissions session notes rely on. Existing overwrites are edited
+// in place rather than appended to, so manual tweaks to other members and
+// roles survive the reset.
+func applyResetDeskPermissions(s *discordgo.Session, channel *discordgo.Channel, userId string) error {
+	required := map[string]int64{
+		userId:          USER_DESK_PERMISSIONS,
+		s.State.User.ID: BOT_DESK_PERMISSIONS,
+	}
+	if desk, ok := deskStore.DeskByChannel(channel.GuildID, channel.ID); ok {
+		for _, guestID := range desk.Guests {
+			required[guestID] |= GUEST_DESK_PERMISSIONS
+		}
+		if desk.Notes {
+			required[userId] |= NOTES_DESK_PERMISSIONS
+			required[s.State.User.ID] |= discordgo.PermissionSendMessages
+			for _, guestID := range desk.Guests {
+				required[guestID] |= NOTES_DESK_PERMISSIONS
 			}
 		}
 	}
 
-	if (userPermissions&USER_DESK_PERMISSIONS == USER_DESK_PERMISSIONS) &&
-		(botPermissions&BOT_DESK_PERMISSIONS == BOT_DESK_PERMISSIONS) {
+	overwrites := channel.PermissionOverwrites
+	changed := false
+	for id, permissions := range required {
+		existing := permissionOverwrite(channel, id, discordgo.PermissionOverwriteTypeMember)
+		if existing.Allow&permissions == permissions && existing.Deny&permissions == 0 {
+			continue
+		}
+		overwrites = upsertPermissionOverwrite(overwrites, &discordgo.PermissionOverwrite{
+			ID:    id,
+			Type:  discordgo.PermissionOverwriteTypeMember,
+			Allow: existing.Allow | permissions,
+			Deny:  existing.Deny &^ permissions,
+		})
+		changed = true
+	}
+	if !changed {
 		return nil
 	}
 
-	_, err := s.ChannelEdit(channel.ID, &discordgo.ChannelEdit{
-		PermissionOverwrites: append(
-			channel.PermissionOverwrites,
-			&discordgo.PermissionOverwrite{
-				ID:    userId,
-				Type:  discordgo.PermissionOverwriteTypeMember,
-				Allow: userPermissions | USER_DESK_PERMISSIONS,
-			},
-			&discordgo.PermissionOverwrite{
-				ID:    s.State.User.ID,
-				Type:  discordgo.PermissionOverwriteTypeMember,
-				Allow: botPermissions | BOT_DESK_PERMISSIONS,
-			},
-			&discordgo.PermissionOverwrite{
-				ID:   channel.GuildID,
-				Type: discordgo.PermissionOverwriteTypeRole,
-				Deny: discordgo.PermissionViewChannel,
-			},
-		),
+	everyone := permissionOverwrite(channel, channel.GuildID, discordgo.PermissionOverwriteTypeRole)
+	_, err := editChannel(s, channel.ID, &discordgo.ChannelEdit{
+		PermissionOverwrites: upsertPermissionOverwrite(overwrites, &discordgo.PermissionOverwrite{
+			ID:    channel.GuildID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: everyone.Allow &^ discordgo.PermissionViewChannel,
+			Deny:  everyone.Deny | discordgo.PermissionViewChannel,
+		}),
 	})
 	return err
 }
+
+// setDeskGuestAccess queues applyDeskGuestAccess and waits for it.
+func setDeskGuestAccess(s *discordgo.Session, channel *discordgo.Channel, guestID string, allowed bool) error {
+	return runDeskEdit(channel.GuildID, guestEditKey(channel, guestID), func() error {
+		return applyDeskGuestAccess(s, currentChannel(s, channel), guestID, allowed)
+	})
+}
+
+// guestEditKey is the queue key for granting or revoking one member's access
+// to a desk, so only the latest of them runs.
+func guestEditKey(channel *discordgo.Channel, guestID string) string {
+	return "guest:" + channel.ID + ":" + guestID
+}
+
+// applyDeskGuestAccess grants or revokes a guest's view and connect
+// permissions on a desk, leaving any other bits on their overwrite alone.
+func applyDeskGuestAccess(s *discordgo.Session, channel *discordgo.Channel, guestID string, allowed bool) error {
+	guest := permissionOverwrite(channel, guestID, discordgo.PermissionOverwriteTypeMember)
+	if allowed {
+		guest.Allow |= GUEST_DESK_PERMISSIONS
+		guest.Deny &^= GUEST_DESK_PERMISSIONS
+	} else {
+		guest.Allow &^= GUEST_DESK_PERMISSIONS
+	}
+
+	var overwrites []*discordgo.PermissionOverwrite
+	if guest.Allow == 0 && guest.Deny == 0 {
+		overwrites = removePermissionOverwrite(channel.PermissionOverwrites, guestID)
+	} else {
+		overwrites = upsertPermissionOverwrite(channel.PermissionOverwrites, &guest)
+	}
+	_, err := editChannel(s, channel.ID, &discordgo.ChannelEdit{PermissionOverwrites: overwrites})
+	return err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMissingPermissions(t *testing.T) {
+	missingAccess := &discordgo.RESTError{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  &discordgo.APIErrorMessage{Code: discordgo.ErrCodeMissingAccess},
+	}
+	missingPerms := &discordgo.RESTError{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  &discordgo.APIErrorMessage{Code: discordgo.ErrCodeMissingPermissions},
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"missing permissions", missingPerms, true},
+		{"forbidden", missingAccess, true},
+		{"server error", restError(http.StatusInternalServerError, ""), false},
+		{"rate limited", restError(http.StatusTooManyRequests, ""), false},
+		{"network error", &net.DNSError{IsTimeout: true}, false},
+	}
+	for _, tc := range cases {
+		if got := missingPermissions(tc.err); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
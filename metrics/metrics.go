@@ -0,0 +1,156 @@
+// Package metrics implements labelled counters and serves them in the
+// Prometheus text exposition format.
+//
+// It covers just what the bot needs, so it doesn't pull in the Prometheus
+// client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Registry holds a set of counters and writes them out together.
+type Registry struct {
+	mu       sync.Mutex
+	counters []*Counter
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter registers and returns a counter. name should follow Prometheus
+// conventions, e.g. "deskbot_voice_events_total". Each call to Inc must pass
+// one value per label, in order. Registering the same name twice panics, as
+// that is a programming error.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.counters {
+		if c.name == name {
+			panic("metrics: duplicate counter " + name)
+		}
+	}
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]*series)}
+	r.counters = append(r.counters, c)
+	return c
+}
+
+// WriteText writes every counter in the Prometheus text format, in
+// registration order with series sorted by label values.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	counters := slices.Clone(r.counters)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range counters {
+		c.writeText(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the registry's counters, so a Registry can be mounted at
+// /metrics directly.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
+
+// Counter is a monotonically increasing count, split into series by label
+// values. It is safe for concurrent use.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*series // key → series
+}
+
+// series is one combination of label values and its count.
+type series struct {
+	labelValues []string
+	count       uint64
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds n to the series with the given label values.
+func (c *Counter) Add(n uint64, labelValues ...string) {
+	if len(labelValues) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", c.name, len(c.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		c.values[key] = s
+	}
+	s.count += n
+}
+
+// Value returns the count of the series with the given label values.
+func (c *Counter) Value(labelValues ...string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[strings.Join(labelValues, "\xff")]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// writeText writes the counter's HELP, TYPE and series lines.
+func (c *Counter) writeText(w io.Writer) {
+	c.mu.Lock()
+	all := make([]series, 0, len(c.values))
+	for _, s := range c.values {
+		all = append(all, *s)
+	}
+	c.mu.Unlock()
+	slices.SortFunc(all, func(a, b series) int {
+		return slices.Compare(a.labelValues, b.labelValues)
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, escapeHelp(c.help))
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	if len(c.labels) == 0 && len(all) == 0 {
+		// An unlabelled counter that was never incremented is still zero.
+		fmt.Fprintf(w, "%s 0\n", c.name)
+		return
+	}
+	for _, s := range all {
+		fmt.Fprintf(w, "%s%s %d\n", c.name, c.formatLabels(s.labelValues), s.count)
+	}
+}
+
+// formatLabels renders label values as {name="value",...}.
+func (c *Counter) formatLabels(values []string) string {
+	if len(c.labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(c.labels))
+	for i, name := range c.labels {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
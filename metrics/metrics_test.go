@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounter_Inc(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_total", "A test.", "kind")
+
+	c.Inc("a")
+	c.Inc("a")
+	c.Add(3, "b")
+
+	if got := c.Value("a"); got != 2 {
+		t.Errorf("a: got %d, want 2", got)
+	}
+	if got := c.Value("b"); got != 3 {
+		t.Errorf("b: got %d, want 3", got)
+	}
+	if got := c.Value("c"); got != 0 {
+		t.Errorf("c: got %d, want 0", got)
+	}
+}
+
+func TestCounter_WrongLabelCountPanics(t *testing.T) {
+	c := NewRegistry().Counter("test_total", "A test.", "kind")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	c.Inc()
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("test_total", "A test.")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	r.Counter("test_total", "Again.")
+}
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	events := r.Counter("events_total", "Events seen.")
+	cmds := r.Counter("commands_total", "Commands handled.", "command", "guild")
+
+	events.Inc()
+	cmds.Inc("prbuddy pto", "g2")
+	cmds.Inc("desk mode", "g1")
+	cmds.Inc("desk mode", "g1")
+	cmds.Inc(`say "hi"`+"\n", `a\b`)
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	want := `# HELP events_total Events seen.
+# TYPE events_total counter
+events_total 1
+# HELP commands_total Commands handled.
+# TYPE commands_total counter
+commands_total{command="desk mode",guild="g1"} 2
+commands_total{command="prbuddy pto",guild="g2"} 1
+commands_total{command="say \"hi\"\n",guild="a\\b"} 1
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRegistry_UnusedCounters(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("plain_total", "Never incremented.")
+	r.Counter("labelled_total", "Never incremented either.", "kind")
+
+	var out strings.Builder
+	_ = r.WriteText(&out)
+	if !strings.Contains(out.String(), "plain_total 0\n") {
+		t.Errorf("unlabelled counter should report zero:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "labelled_total{") {
+		t.Errorf("labelled counter should have no series:\n%s", out.String())
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x_total", "X.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type: got %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "x_total 1\n") {
+		t.Errorf("body: got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/metrics"
+)
+
+// ---------------------------------------------------------------------------
+// Health and metrics
+// ---------------------------------------------------------------------------
+
+// gatewayStaleAfter is how long the gateway may go without a heartbeat ACK
+// before /healthz reports it down. Discord asks for a heartbeat about every
+// 41 seconds.
+const gatewayStaleAfter = 2 * time.Minute
+
+var (
+	registry = metrics.NewRegistry()
+
+	voiceEvents = registry.Counter("deskbot_voice_events_total",
+		"Voice state updates received.")
+	deskVisibilityChanges = registry.Counter("deskbot_desk_visibility_changes_total",
+		"Desks shown to or hidden from @everyone.", "action")
+	discordAPIErrors = registry.Counter("deskbot_discord_api_errors_total",
+		"Failed Discord REST requests, by HTTP status or \"transport\" when no response came back.", "status")
+	commandsHandled = registry.Counter("deskbot_commands_total",
+		"Slash commands handled, by full command path.", "command")
+	deskEditRetries = registry.Counter("deskbot_desk_edit_retries_total",
+		"Queued desk edits retried after a rate limit or transient failure.")
+	deskEditsCoalesced = registry.Counter("deskbot_desk_edits_coalesced_total",
+		"Queued desk edits replaced by a newer edit of the same desk before they ran.")
+	pairingRuns = registry.Counter("deskbot_pairing_runs_total",
+		"PR buddy pairings generated, by guild and what triggered them.", "guild", "trigger")
+)
+
+// countingTransport counts failed Discord REST requests before handing the
+// response back to discordgo, which does its own rate-limit retries.
+type countingTransport struct {
+	next http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		discordAPIErrors.Inc("transport")
+	case resp.StatusCode >= 400:
+		discordAPIErrors.Inc(strconv.Itoa(resp.StatusCode))
+	}
+	return resp, err
+}
+
+// countAPIErrors makes the session's REST client count its failures.
+func countAPIErrors(s *discordgo.Session) {
+	next := s.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	s.Client.Transport = countingTransport{next: next}
+}
+
+// health is the body of /healthz.
+type health struct {
+	GatewayConnected bool      `json:"gateway_connected"`
+	LastHeartbeatAck time.Time `json:"last_heartbeat_ack"`
+	SchedulerAlive   bool      `json:"scheduler_alive"`
+	LastSchedulerRun time.Time `json:"last_scheduler_run"`
+}
+
+// ok reports whether every part of the bot that is switched on is working.
+func (h health) ok() bool {
+	return h.GatewayConnected && (h.SchedulerAlive || !cfg.Features.PRBuddy)
+}
+
+// checkHealth reports on the gateway connection and the PR buddy scheduler.
+func checkHealth(s *discordgo.Session) health {
+	s.RLock()
+	ready, lastAck := s.DataReady, s.LastHeartbeatAck
+	s.RUnlock()
+
+	h := health{
+		GatewayConnected: ready && time.Since(lastAck) < gatewayStaleAfter,
+		LastHeartbeatAck: lastAck,
+	}
+	if cfg.Features.PRBuddy {
+		h.SchedulerAlive = buddy.SchedulerAlive()
+		h.LastSchedulerRun = buddy.SchedulerHeartbeat()
+	}
+	return h
+}
+
+// handleHealthz serves the bot's health as JSON, with 503 when unhealthy so
+// a liveness probe restarts a wedged bot.
+func handleHealthz(s *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		h := checkHealth(s)
+		w.Header().Set("Content-Type", "application/json")
+		if !h.ok() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(h)
+	}
+}
+
+// startHTTPServer serves /healthz and /metrics on addr until the returned
+// stop function is called.
+func startHTTPServer(s *discordgo.Session, addr string) (stop func()) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", handleHealthz(s))
+	mux.Handle("GET /metrics", registry)
+
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		logger.Info("Serving health and metrics", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Health and metrics server failed", "addr", addr, "err", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("Failed to stop health and metrics server", "err", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/audit"
+	"github.com/cbarber/deskbot/prbuddy"
+)
+
+// ---------------------------------------------------------------------------
+// Pairing direct messages
+// ---------------------------------------------------------------------------
+
+// dmPairings messages each paired member their buddy for the week, and the
+// sitting-out member why they have none, skipping anyone who opted out. It
+// returns the user IDs that couldn't be messaged, usually because they don't
+// accept DMs from server members.
+func dmPairings(s *discordgo.Session, guildID string, result prbuddy.Result) []string {
+	week := result.Week.Format("Jan 2, 2006")
+	footer := "\n-# Use `/prbuddy dms state:off` to stop these messages."
+
+	var failed []string
+	send := func(m *prbuddy.Member, msg string) {
+		if m.DMOptOut {
+			return
+		}
+		if err := sendDM(s, m.UserID, msg+footer); err != nil {
+			logger.Warn("prbuddy: failed to DM", "guild", guildID, "user", m.UserID, "err", err)
+			failed = append(failed, m.UserID)
+		}
+	}
+
+	for _, p := range result.Pairs {
+		send(p.A, fmt.Sprintf("👋 Your PR buddy for the week of %s is <@%s> (%s).", week, p.B.UserID, p.B.Name))
+		send(p.B, fmt.Sprintf("👋 Your PR buddy for the week of %s is <@%s> (%s).", week, p.A.UserID, p.A.Name))
+	}
+	if result.SittingOut != nil {
+		send(result.SittingOut, fmt.Sprintf("You're sitting out PR buddy pairings for the week of %s. "+
+			"There's an odd number of people available, so one person goes without a buddy, "+
+			"and it won't be you two weeks running.", week))
+	}
+
+	if len(failed) > 0 {
+		logger.Warn("prbuddy: couldn't DM some members", "guild", guildID, "users", failed)
+	}
+	return failed
+}
+
+// sendDM opens a DM channel with the user and sends msg to it.
+func sendDM(s *discordgo.Session, userID, msg string) error {
+	channel, err := s.UserChannelCreate(userID)
+	if err != nil {
+		return err
+	}
+	_, err = s.ChannelMessageSend(channel.ID, msg)
+	return err
+}
+
+// formatDMFailures renders the members who couldn't be messaged for an admin.
+func formatDMFailures(failed []string) string {
+	return fmt.Sprintf("⚠️ Couldn't DM %s — they may have DMs from server members turned off.", userMentions(failed))
+}
+
+// auditDMFailures reports members a scheduled run couldn't DM to the audit
+// channel, as there's no admin to reply to.
+func auditDMFailures(s *discordgo.Session, guildID string, failed []string) {
+	if len(failed) == 0 {
+		return
+	}
+	recordAudit(s, guildID, audit.Entry{Action: "PR buddy DMs failed", Target: userMentions(failed)})
+}
+
+// userMentions joins mentions of the given users with commas.
+func userMentions(userIDs []string) string {
+	mentions := make([]string, len(userIDs))
+	for idx, userID := range userIDs {
+		mentions[idx] = userMention(userID)
+	}
+	return strings.Join(mentions, ", ")
+}
+
+func handleDMs(s *discordgo.Session, i *discordgo.InteractionCreate, opts []*discordgo.ApplicationCommandInteractionDataOption) {
+	if i.Member == nil {
+		respond(s, i, "PR buddy commands only work inside a server.")
+		return
+	}
+	on := opts[0].StringValue() == "on"
+	before := "off"
+	if m := buddyMember(i.GuildID, i.Member.User.ID); m != nil {
+		before = onOff(!m.DMOptOut)
+	}
+	if err := buddy.SetDMOptOut(i.GuildID, i.Member.User.ID, !on); err != nil {
+		respond(s, i, fmt.Sprintf("Failed to change your PR buddy DMs: %v", err))
+		return
+	}
+	auditInteraction(s, i, "PR buddy DMs set", userMention(i.Member.User.ID), before, onOff(on))
+	if on {
+		respond(s, i, "You'll get your PR buddy by DM each week.")
+		return
+	}
+	respond(s, i, fmt.Sprintf("You won't get PR buddy DMs any more. Pairings are still posted in #%s.", cfg.Channels.Pairings))
+}
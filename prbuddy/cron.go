@@ -0,0 +1,165 @@
+package prbuddy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week. Fields accept "*", single values, ranges ("1-5"),
+// lists ("1,3") and steps ("*/15", "0-30/10"). Day of week runs 0–6 from
+// Sunday, and 7 is also Sunday. As in cron, when both day fields are
+// restricted a day matches if either does.
+type Spec struct {
+	expr                     string
+	minute, hour, dom, month uint64
+	dow                      uint64
+	domAny, dowAny           bool
+}
+
+// cronField describes the valid range of one cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSpec parses a cron expression such as "0 9 * * 1" (Mondays at 09:00).
+func ParseSpec(expr string) (Spec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return Spec{}, fmt.Errorf("cron spec %q: want 5 fields, got %d", expr, len(fields))
+	}
+
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return Spec{}, fmt.Errorf("cron spec %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	// Fold 7 into Sunday.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return Spec{
+		expr:   strings.Join(fields, " "),
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+// String returns the spec's cron expression.
+func (s Spec) String() string {
+	return s.expr
+}
+
+// Next returns the first time strictly after t that matches the spec, in t's
+// location. It returns the zero time if nothing matches within five years,
+// e.g. for "0 0 31 2 *".
+func (s Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	for i := 0; i < 5*366; i++ {
+		if s.matchesDay(day) {
+			for h := 0; h < 24; h++ {
+				if s.hour&(1<<h) == 0 {
+					continue
+				}
+				for min := 0; min < 60; min++ {
+					if s.minute&(1<<min) == 0 {
+						continue
+					}
+					y, m, d := day.Date()
+					candidate := time.Date(y, m, d, h, min, 0, 0, loc)
+					if candidate.After(t) {
+						return candidate
+					}
+				}
+			}
+		}
+		y, m, d := day.Date()
+		day = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	}
+	return time.Time{}
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// matchesDay reports whether the spec fires at some point on day.
+func (s Spec) matchesDay(day time.Time) bool {
+	if s.month&(1<<uint(day.Month())) == 0 {
+		return false
+	}
+	domOK := s.dom&(1<<uint(day.Day())) != 0
+	dowOK := s.dow&(1<<uint(day.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowOK
+	case s.dowAny:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+// parseCronField turns one comma-separated cron field into a bitset.
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: bad step %q", f.name, stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("%s: bad value %q", f.name, loStr)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("%s: bad value %q", f.name, hiStr)
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s: %q is outside %d-%d", f.name, rangePart, f.min, f.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
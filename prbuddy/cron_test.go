@@ -0,0 +1,97 @@
+package prbuddy
+
+import (
+	"testing"
+	"time"
+)
+
+// --- ParseSpec --------------------------------------------------------------
+
+func TestParseSpec_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 9 * *",
+		"60 9 * * 1",
+		"0 24 * * 1",
+		"0 9 0 * *",
+		"0 9 * 13 *",
+		"0 9 * * 8",
+		"0 9 * * mon",
+		"*/0 9 * * 1",
+		"0 9-5 * * 1",
+	} {
+		if _, err := ParseSpec(expr); err == nil {
+			t.Errorf("ParseSpec(%q): expected error", expr)
+		}
+	}
+}
+
+func TestParseSpec_String(t *testing.T) {
+	spec, err := ParseSpec("  0  9 * *   1 ")
+	if err != nil {
+		t.Fatalf("ParseSpec: %v", err)
+	}
+	if got := spec.String(); got != "0 9 * * 1" {
+		t.Errorf("String: got %q", got)
+	}
+}
+
+// --- Spec.Next --------------------------------------------------------------
+
+func TestSpecNext(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2026, 4, 8, 10, 7, 0, 0, time.UTC)
+	at := func(d, h, m int) time.Time { return time.Date(2026, 4, d, h, m, 0, 0, time.UTC) }
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", at(8, 10, 8)},
+		{"*/15 * * * *", at(8, 10, 15)},
+		{"0 9 * * 1-5", at(9, 9, 0)},
+		{"0 9,17 * * *", at(8, 17, 0)},
+		{"30 8 * * 0", at(12, 8, 30)},
+		{"30 8 * * 7", at(12, 8, 30)},
+		{"0 0 1 * *", time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 10th or any Monday, whichever is first.
+		{"0 9 10 * 1", at(10, 9, 0)},
+		{"0 9 20 * 1", at(13, 9, 0)},
+		{"0 12 * 6-8/2 *", time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		spec, err := ParseSpec(tc.expr)
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %v", tc.expr, err)
+			continue
+		}
+		if got := spec.Next(now); !got.Equal(tc.want) {
+			t.Errorf("Next(%q) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestSpecNext_UsesLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	spec, _ := ParseSpec(DefaultPairingSpec)
+
+	// Sunday 23:00 UTC is already Monday 08:00 in Tokyo.
+	now := time.Date(2026, 4, 12, 23, 0, 0, 0, time.UTC).In(tokyo)
+	want := time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)
+	if got := spec.Next(now); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSpecNext_NeverMatches(t *testing.T) {
+	spec, err := ParseSpec("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("ParseSpec: %v", err)
+	}
+	if got := spec.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("want zero time, got %v", got)
+	}
+}
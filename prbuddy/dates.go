@@ -0,0 +1,146 @@
+package prbuddy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout is the format PTO dates are shown and accepted in.
+const DateLayout = "2006-01-02"
+
+// DateSuggestion is a date offered while a member types a PTO date.
+type DateSuggestion struct {
+	// Label describes the date, e.g. "next monday (Mon Apr 13)".
+	Label string
+	// Date is midnight on the suggested day.
+	Date time.Time
+}
+
+// datePhrases are offered, in order, when suggesting PTO dates.
+var datePhrases = []string{
+	"today",
+	"tomorrow",
+	"next monday",
+	"next tuesday",
+	"next wednesday",
+	"next thursday",
+	"next friday",
+	"next week",
+	"in 1 week",
+	"in 2 weeks",
+	"in 3 weeks",
+	"in 4 weeks",
+}
+
+// ParseDate reads a PTO date relative to now. It accepts YYYY-MM-DD and the
+// phrases "today", "tomorrow", "next week" (the coming Monday), a weekday
+// optionally prefixed with "next" (its next occurrence after today), and
+// "in N days" or "in N weeks". The result is midnight in now's location.
+func ParseDate(input string, now time.Time) (time.Time, error) {
+	s := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	today := midnight(now)
+
+	if d, err := time.ParseInLocation(DateLayout, s, now.Location()); err == nil {
+		return d, nil
+	}
+
+	switch s {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "next week":
+		return nextWeekday(today, time.Monday), nil
+	}
+
+	if day, ok := parseWeekday(strings.TrimPrefix(s, "next ")); ok {
+		return nextWeekday(today, day), nil
+	}
+
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		fields := strings.Fields(rest)
+		if len(fields) == 2 {
+			n, err := strconv.Atoi(fields[0])
+			if err == nil && n >= 0 {
+				switch strings.TrimSuffix(fields[1], "s") {
+				case "day":
+					return today.AddDate(0, 0, n), nil
+				case "week":
+					return today.AddDate(0, 0, 7*n), nil
+				}
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %q — use YYYY-MM-DD or a phrase like \"next monday\" or \"in 2 weeks\"", input)
+}
+
+// SuggestDates returns up to limit dates matching what a member has typed so
+// far, skipping any before notBefore. Input that already parses is offered
+// first.
+func SuggestDates(input string, now, notBefore time.Time, limit int) []DateSuggestion {
+	typed := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	notBefore = midnight(notBefore)
+
+	var out []DateSuggestion
+	seen := make(map[string]bool)
+	add := func(label string, d time.Time) {
+		if len(out) >= limit || d.Before(notBefore) || seen[label] {
+			return
+		}
+		seen[label] = true
+		out = append(out, DateSuggestion{
+			Label: fmt.Sprintf("%s (%s)", label, d.Format("Mon Jan 2")),
+			Date:  d,
+		})
+	}
+
+	if d, err := ParseDate(typed, now); err == nil && typed != "" {
+		add(typed, d)
+	}
+	for _, phrase := range datePhrases {
+		if !strings.Contains(phrase, typed) {
+			continue
+		}
+		if d, err := ParseDate(phrase, now); err == nil {
+			add(phrase, d)
+		}
+	}
+	// Fill out the list with plain dates over the next fortnight.
+	for days := 0; days < 14; days++ {
+		d := midnight(now).AddDate(0, 0, days)
+		if label := d.Format(DateLayout); strings.HasPrefix(label, typed) {
+			add(label, d)
+		}
+	}
+	return out
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// midnight returns the start of t's day in t's location.
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// nextWeekday returns the first day strictly after today that falls on day.
+func nextWeekday(today time.Time, day time.Weekday) time.Time {
+	days := (int(day) - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+// parseWeekday reads a full weekday name such as "monday".
+func parseWeekday(s string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.ToLower(day.String()) == s {
+			return day, true
+		}
+	}
+	return 0, false
+}
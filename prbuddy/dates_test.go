@@ -0,0 +1,84 @@
+package prbuddy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- ParseDate --------------------------------------------------------------
+
+func TestParseDate(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2026, 4, 8, 15, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 4, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2026-04-20", day(20)},
+		{"today", day(8)},
+		{"Tomorrow", day(9)},
+		{"next monday", day(13)},
+		{"friday", day(10)},
+		{"next wednesday", day(15)},
+		{"next week", day(13)},
+		{"in 3 days", day(11)},
+		{"in 1 week", day(15)},
+		{"in  2 weeks", day(22)},
+	}
+	for _, tc := range cases {
+		got, err := ParseDate(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseDate_Invalid(t *testing.T) {
+	now := time.Date(2026, 4, 8, 0, 0, 0, 0, time.UTC)
+	for _, in := range []string{"", "soon", "in weeks", "in -1 days", "2026-13-01"} {
+		if _, err := ParseDate(in, now); err == nil {
+			t.Errorf("ParseDate(%q): expected error", in)
+		}
+	}
+}
+
+// --- SuggestDates -----------------------------------------------------------
+
+func TestSuggestDates_FiltersByInput(t *testing.T) {
+	now := time.Date(2026, 4, 8, 9, 0, 0, 0, time.UTC)
+
+	got := SuggestDates("next", now, now, 25)
+	if len(got) == 0 {
+		t.Fatal("expected suggestions")
+	}
+	for _, s := range got {
+		if !strings.HasPrefix(s.Label, "next") {
+			t.Errorf("unexpected suggestion %q for \"next\"", s.Label)
+		}
+	}
+}
+
+func TestSuggestDates_SkipsBeforeNotBefore(t *testing.T) {
+	now := time.Date(2026, 4, 8, 9, 0, 0, 0, time.UTC)
+	notBefore := now.AddDate(0, 0, 7)
+
+	for _, s := range SuggestDates("", now, notBefore, 25) {
+		if s.Date.Before(time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("suggestion %q is before notBefore", s.Label)
+		}
+	}
+}
+
+func TestSuggestDates_Limit(t *testing.T) {
+	now := time.Date(2026, 4, 8, 9, 0, 0, 0, time.UTC)
+	if got := SuggestDates("", now, now, 5); len(got) != 5 {
+		t.Errorf("want 5 suggestions, got %d", len(got))
+	}
+}
@@ -6,10 +6,14 @@
 package prbuddy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"log/slog"
 	"math/rand"
 	"os"
+	"slices"
 	"sync"
 	"time"
 )
@@ -22,6 +26,8 @@ type Member struct {
 	Name string `json:"name"`
 	// PTO holds the member's current leave window, or nil if they are available.
 	PTO *PTOWindow `json:"pto,omitempty"`
+	// DMOptOut is set when the member doesn't want their pairing by DM.
+	DMOptOut bool `json:"dm_opt_out,omitempty"`
 }
 
 // PTOWindow describes a single leave period for a member.
@@ -30,6 +36,8 @@ type Member struct {
 type PTOWindow struct {
 	LeaveOn   time.Time `json:"leave_on"`
 	ReturnsOn time.Time `json:"returns_on"`
+	// Note is an optional reason or handover note from the member.
+	Note string `json:"note,omitempty"`
 }
 
 // Pair is two members matched for a week of code review.
@@ -46,12 +54,53 @@ type Result struct {
 	Pairs []Pair
 	// SittingOut is the member who has no pair this week, or nil.
 	SittingOut *Member
+	// Done lists the user IDs of members who have finished reviewing.
+	Done []string
+	// OnPTO lists members who are away for the week.
+	OnPTO []*Member
+}
+
+// PairingMode controls how Generate uses the affinity signal when matching
+// members.
+type PairingMode string
+
+const (
+	// ModeRandom ignores affinity and pairs members at random.
+	ModeRandom PairingMode = "random"
+	// ModeStretch prefers pairing members who rarely work together.
+	ModeStretch PairingMode = "stretch"
+	// ModeComfort prefers pairing members who already work together.
+	ModeComfort PairingMode = "comfort"
+)
+
+// AffinityFunc reports how strongly each pair of members in a guild already
+// collaborates. Keys are the two user IDs in ascending order; pairs that are
+// missing have no affinity.
+type AffinityFunc func(guildID string) map[[2]string]float64
+
+// AffinityKey returns the map key AffinityFunc uses for two user IDs.
+func AffinityKey(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
 }
 
 // store is the JSON-serialisable state for a single guild.
 type store struct {
-	Members      []*Member `json:"members"`
-	LastSatOutID string    `json:"last_sat_out_id,omitempty"`
+	Members      []*Member   `json:"members"`
+	LastSatOutID string      `json:"last_sat_out_id,omitempty"`
+	PairingMode  PairingMode `json:"pairing_mode,omitempty"`
+	// Week is the most recently generated pairing week, as adjusted by
+	// members since.
+	Week *week `json:"week,omitempty"`
+	// TimeZone is the IANA name of the guild's time zone for scheduled jobs,
+	// or "" for the bot's local time.
+	TimeZone string `json:"time_zone,omitempty"`
+	// Schedules overrides the cron spec of scheduled jobs by job name.
+	Schedules map[string]string `json:"schedules,omitempty"`
+	// NextRuns is when each scheduled job next fires, by job name.
+	NextRuns map[string]time.Time `json:"next_runs,omitempty"`
 }
 
 // Bot is the PR buddy engine. Construct one with New and call its methods
@@ -61,25 +110,96 @@ type Bot struct {
 	path     string
 	guilds   map[string]*store // guild ID → state
 	randSrc  *rand.Rand
+	weekSeed bool
+	loc      *time.Location // default schedule time zone
+	pairSpec string         // default JobPairings schedule
+	log      *slog.Logger
+	lastTick time.Time // when the scheduler last woke; zero when stopped
 	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{} // closed when the scheduler exits; nil until started
+	wakeCh   chan struct{}
+	clock    Clock
+	jobs     []*job
 	postFunc func(guildID string, result Result)
+	remind   ReminderFunc
+	affinity AffinityFunc
+}
+
+// Option configures a Bot in New.
+type Option func(*Bot)
+
+// WithClock makes the Bot's scheduler use c instead of the wall clock.
+func WithClock(c Clock) Option {
+	return func(b *Bot) { b.clock = c }
+}
+
+// WithSeed makes the Bot's random choices reproducible from seed.
+func WithSeed(seed int64) Option {
+	return WithRandSource(rand.NewSource(seed))
+}
+
+// WithRandSource makes the Bot draw its random choices from src.
+func WithRandSource(src rand.Source) Option {
+	return func(b *Bot) { b.randSrc = rand.New(src) }
+}
+
+// WithWeekSeeding makes Generate shuffle with a seed derived from the guild
+// and week, so generating the same week again yields the same pairings until
+// someone calls Reroll. Pairings can then be reproduced when disputed.
+func WithWeekSeeding() Option {
+	return func(b *Bot) { b.weekSeed = true }
+}
+
+// WithPairingSchedule makes spec the default pairings schedule in place of
+// DefaultPairingSpec. Guilds can still override it with SetSchedule. An
+// invalid spec leaves the default unchanged.
+func WithPairingSchedule(spec string) Option {
+	return func(b *Bot) {
+		if parsed, err := ParseSpec(spec); err == nil && !parsed.Next(time.Now()).IsZero() {
+			b.pairSpec = spec
+		}
+	}
+}
+
+// WithLocation makes loc the time zone for guilds that haven't set one,
+// instead of local time.
+func WithLocation(loc *time.Location) Option {
+	return func(b *Bot) { b.loc = loc }
+}
+
+// WithLogger makes the Bot log scheduled runs and persistence failures to l
+// instead of slog's default logger.
+func WithLogger(l *slog.Logger) Option {
+	return func(b *Bot) { b.log = l }
 }
 
 // New creates a Bot that persists state to the given file path.
-// postFunc is called every Monday at 09:00 local time with the week's pairings
-// for each guild. It is the caller's responsibility to format and send the
-// Discord message.
-func New(path string, postFunc func(guildID string, result Result)) (*Bot, error) {
+// postFunc is called with the week's pairings for each guild whenever the
+// pairings job runs, every Monday at 09:00 unless the guild changes it. It is
+// the caller's responsibility to format and send the Discord message.
+func New(path string, postFunc func(guildID string, result Result), opts ...Option) (*Bot, error) {
 	b := &Bot{
 		path:     path,
 		guilds:   make(map[string]*store),
-		randSrc:  rand.New(rand.NewSource(time.Now().UnixNano())),
 		stopCh:   make(chan struct{}),
+		wakeCh:   make(chan struct{}, 1),
+		clock:    systemClock{},
+		loc:      time.Local,
+		pairSpec: DefaultPairingSpec,
+		log:      slog.Default(),
 		postFunc: postFunc,
 	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.randSrc == nil {
+		b.randSrc = rand.New(rand.NewSource(b.clock.Now().UnixNano()))
+	}
 	if err := b.load(); err != nil {
 		return nil, err
 	}
+	b.registerJobs()
 	return b, nil
 }
 
@@ -125,6 +245,11 @@ func (b *Bot) RemoveMember(guildID, userID string) error {
 // leaveOn is the first day of absence; returnsOn is the first day back.
 // returnsOn must be after leaveOn.
 func (b *Bot) SetPTO(guildID, userID string, leaveOn, returnsOn time.Time) error {
+	return b.SetPTOWithNote(guildID, userID, leaveOn, returnsOn, "")
+}
+
+// SetPTOWithNote is SetPTO with a note attached to the leave window.
+func (b *Bot) SetPTOWithNote(guildID, userID string, leaveOn, returnsOn time.Time, note string) error {
 	if !returnsOn.After(leaveOn) {
 		return fmt.Errorf("returns_on must be after leave_on")
 	}
@@ -138,6 +263,7 @@ func (b *Bot) SetPTO(guildID, userID string, leaveOn, returnsOn time.Time) error
 			m.PTO = &PTOWindow{
 				LeaveOn:   leaveOn.UTC().Truncate(24 * time.Hour),
 				ReturnsOn: returnsOn.UTC().Truncate(24 * time.Hour),
+				Note:      note,
 			}
 			return b.save()
 		}
@@ -145,6 +271,21 @@ func (b *Bot) SetPTO(guildID, userID string, leaveOn, returnsOn time.Time) error
 	return fmt.Errorf("user %s is not a member of the team", userID)
 }
 
+// MissedWeeks returns the Mondays of the pairing weeks a member on leave from
+// leaveOn until returnsOn will sit out, using the same rule as Generate.
+func MissedWeeks(leaveOn, returnsOn time.Time) []time.Time {
+	leaveOn = leaveOn.UTC().Truncate(24 * time.Hour)
+	returnsOn = returnsOn.UTC().Truncate(24 * time.Hour)
+
+	var out []time.Time
+	for monday := weekMonday(leaveOn); monday.Before(returnsOn); monday = monday.AddDate(0, 0, 7) {
+		if !monday.Before(leaveOn) {
+			out = append(out, monday)
+		}
+	}
+	return out
+}
+
 // ClearPTO removes any PTO window for the given team member.
 func (b *Bot) ClearPTO(guildID, userID string) error {
 	b.mu.Lock()
@@ -160,6 +301,57 @@ func (b *Bot) ClearPTO(guildID, userID string) error {
 	return fmt.Errorf("user %s is not a member of the team", userID)
 }
 
+// SetAffinity installs the collaboration signal used by the stretch and
+// comfort pairing modes. Without one every mode behaves like ModeRandom.
+func (b *Bot) SetAffinity(f AffinityFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.affinity = f
+}
+
+// SetPairingMode chooses how the guild's pairings use the affinity signal.
+func (b *Bot) SetPairingMode(guildID string, mode PairingMode) error {
+	switch mode {
+	case ModeRandom, ModeStretch, ModeComfort:
+	default:
+		return fmt.Errorf("unknown pairing mode %q", mode)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.guild(guildID).PairingMode = mode
+	return b.save()
+}
+
+// SetDMOptOut records whether a team member wants to stop receiving their
+// pairing by direct message.
+func (b *Bot) SetDMOptOut(guildID, userID string, optOut bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	for _, m := range g.Members {
+		if m.UserID == userID {
+			m.DMOptOut = optOut
+			return b.save()
+		}
+	}
+	return fmt.Errorf("user %s is not a member of the team", userID)
+}
+
+// PairingMode returns the guild's pairing mode, defaulting to ModeRandom.
+func (b *Bot) PairingMode(guildID string) PairingMode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if mode := b.guild(guildID).PairingMode; mode != "" {
+		return mode
+	}
+	return ModeRandom
+}
+
 // Members returns a copy of the team roster for the guild.
 func (b *Bot) Members(guildID string) []*Member {
 	b.mu.Lock()
@@ -184,55 +376,167 @@ func (b *Bot) Members(guildID string) []*Member {
 // are available the Result will have an empty Pairs slice and a nil
 // SittingOut — callers should detect this and notify the channel
 // accordingly. The odd-dev-out rotation is persisted so the same person
-// does not sit out two weeks in a row when avoidable.
+// does not sit out two weeks in a row when avoidable. In the stretch and
+// comfort pairing modes members are matched using the affinity signal,
+// with the random shuffle only breaking ties. If the week has already been
+// generated its stored pairings are returned as they stand, including any
+// swaps, outs and check-ins since; only Reroll replaces them.
 func (b *Bot) Generate(guildID string, t time.Time) Result {
+	b.mu.Lock()
+	monday := b.weekOf(guildID, t)
+	if g := b.guild(guildID); g.Week != nil && g.Week.Monday.Equal(monday) {
+		result := g.result()
+		b.mu.Unlock()
+		return result
+	}
+	b.mu.Unlock()
+
+	affinity := b.pairingAffinity(guildID)
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	// Another caller may have generated the week while the lock was free.
+	if g := b.guild(guildID); g.Week != nil && g.Week.Monday.Equal(monday) {
+		return g.result()
+	}
+	return b.generate(guildID, monday, 0, affinity)
+}
+
+// Reroll generates the week containing t afresh, giving different pairings
+// from last time even with WithWeekSeeding.
+func (b *Bot) Reroll(guildID string, t time.Time) Result {
+	affinity := b.pairingAffinity(guildID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	monday := b.weekOf(guildID, t)
+	rerolls := 1
+	if w := b.guild(guildID).Week; w != nil && w.Monday.Equal(monday) {
+		rerolls = w.Rerolls + 1
+	}
+	return b.generate(guildID, monday, rerolls, affinity)
+}
+
+// StartScheduler launches a background goroutine that runs each guild's
+// scheduled jobs: Generate and postFunc for pairings, and the mid-week
+// reminder if one is set. Call Stop to shut it down cleanly.
+func (b *Bot) StartScheduler() {
+	b.mu.Lock()
+	b.doneCh = make(chan struct{})
+	done := b.doneCh
+	b.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		b.runScheduler()
+	}()
+}
+
+// Stop shuts down the scheduler and waits for it to finish any jobs it is
+// running, so a half-posted pairing isn't cut off. It gives up when ctx is
+// done, returning ctx's error. Calling Stop more than once is safe.
+func (b *Bot) Stop(ctx context.Context) error {
+	b.stopOnce.Do(func() { close(b.stopCh) })
+
+	b.mu.Lock()
+	done := b.doneCh
+	b.mu.Unlock()
+	if done == nil {
+		return nil // never started
+	}
 
-	monday := weekMonday(t)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("prbuddy: scheduler still running: %w", ctx.Err())
+	}
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// pairingAffinity returns the affinity signal for the guild's pairing mode,
+// or nil when the mode doesn't use one. The signal can take a while to
+// compute, so it is fetched without holding b.mu.
+func (b *Bot) pairingAffinity(guildID string) map[[2]string]float64 {
+	b.mu.Lock()
+	f, mode := b.affinity, b.guild(guildID).PairingMode
+	b.mu.Unlock()
+
+	if f == nil || (mode != ModeStretch && mode != ModeComfort) {
+		return nil
+	}
+	return f(guildID)
+}
+
+// generate pairs the guild's available members for the week starting monday.
+// rerolls counts how many times the week has been rerolled, and feeds the
+// seed under WithWeekSeeding. affinity comes from pairingAffinity.
+// Caller must hold b.mu.
+func (b *Bot) generate(guildID string, monday time.Time, rerolls int, affinity map[[2]string]float64) Result {
 	g := b.guild(guildID)
 
+	// With week seeding, regenerating a week must reproduce it, so its own
+	// sit-out can't count as last week's.
+	lastSatOutID := g.LastSatOutID
+	if b.weekSeed && g.Week != nil && g.Week.Monday.Equal(monday) {
+		lastSatOutID = g.Week.PrevSatOutID
+	}
+
 	available := available(g.Members, monday)
 
-	result := Result{Week: monday}
+	result := Result{Week: monday, OnPTO: onPTO(g.Members, monday)}
+	save := func() {
+		g.Week = newWeek(result)
+		g.Week.Rerolls = rerolls
+		g.Week.PrevSatOutID = lastSatOutID
+		// Persist updated LastSatOutID and the week. The pairings are still
+		// good for this run, so a failed save is only logged.
+		if err := b.save(); err != nil {
+			b.log.Error("prbuddy: failed to save pairings", "guild", guildID, "err", err)
+		}
+	}
 	if len(available) < 2 {
+		save() // an empty week still refuses stale adjustments
 		return result
 	}
 
 	// Shuffle for randomness.
-	b.randSrc.Shuffle(len(available), func(i, j int) {
+	rng := b.randSrc
+	if b.weekSeed {
+		rng = rand.New(rand.NewSource(weekSeed(guildID, monday, rerolls)))
+	}
+	rng.Shuffle(len(available), func(i, j int) {
 		available[i], available[j] = available[j], available[i]
 	})
 
 	if len(available)%2 == 1 {
 		// Pick who sits out: prefer not repeating last week's sit-out.
-		sitOutIdx := pickSitOut(available, g.LastSatOutID)
+		sitOutIdx := pickSitOut(available, lastSatOutID)
 		result.SittingOut = available[sitOutIdx]
 		g.LastSatOutID = available[sitOutIdx].UserID
 		available = append(available[:sitOutIdx], available[sitOutIdx+1:]...)
-		_ = b.save() // persist updated LastSatOutID
+	}
+
+	if affinity != nil && (g.PairingMode == ModeStretch || g.PairingMode == ModeComfort) {
+		available = matchByAffinity(available, affinity, g.PairingMode == ModeComfort)
 	}
 
 	for i := 0; i+1 < len(available); i += 2 {
 		result.Pairs = append(result.Pairs, Pair{A: available[i], B: available[i+1]})
 	}
+	save()
 	return result
 }
 
-// StartScheduler launches a background goroutine that calls Generate and
-// postFunc for every guild every Monday at 09:00 local time. Call Stop to
-// shut it down cleanly.
-func (b *Bot) StartScheduler() {
-	go b.runScheduler()
+// weekSeed derives a shuffle seed from the guild, week and reroll count.
+func weekSeed(guildID string, monday time.Time, rerolls int) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%d", guildID, monday.Format(DateLayout), rerolls)
+	return int64(h.Sum64())
 }
 
-// Stop shuts down the Monday scheduler.
-func (b *Bot) Stop() {
-	close(b.stopCh)
-}
-
-// --- internal helpers -------------------------------------------------------
-
 // guild returns (creating if necessary) the store for a guild.
 // Caller must hold b.mu.
 func (b *Bot) guild(guildID string) *store {
@@ -276,65 +580,16 @@ func (b *Bot) save() error {
 	return nil
 }
 
-// runScheduler fires every Monday at 09:00 local time.
-func (b *Bot) runScheduler() {
-	for {
-		next := nextMonday9am(time.Now())
-		select {
-		case <-b.stopCh:
-			return
-		case <-time.After(time.Until(next)):
-		}
-
-		now := time.Now()
-		b.mu.Lock()
-		guildIDs := make([]string, 0, len(b.guilds))
-		for id := range b.guilds {
-			guildIDs = append(guildIDs, id)
-		}
-		b.mu.Unlock()
-
-		for _, guildID := range guildIDs {
-			result := b.Generate(guildID, now)
-			b.postFunc(guildID, result)
-		}
-	}
-}
-
-// weekMonday returns the Monday of the ISO week containing t, at midnight UTC.
+// weekMonday returns the Monday of the ISO week containing t's date in t's
+// own location, at midnight UTC. Callers pass t in the guild's time zone so
+// that early Monday there counts as Monday even while it's Sunday in UTC.
 func weekMonday(t time.Time) time.Time {
-	t = t.UTC()
 	weekday := int(t.Weekday())
 	if weekday == 0 {
 		weekday = 7 // Sunday → 7
 	}
-	delta := time.Duration(weekday-1) * 24 * time.Hour
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(-delta)
-}
-
-// nextMonday9am returns the next Monday at 09:00 in the local time zone.
-// If now is already Monday before 09:00, it returns today at 09:00.
-func nextMonday9am(now time.Time) time.Time {
-	loc := now.Location()
-	y, mo, d := now.Date()
-	today9 := time.Date(y, mo, d, 9, 0, 0, 0, loc)
-
-	weekday := now.Weekday()
-	switch {
-	case weekday == time.Monday && now.Before(today9):
-		return today9
-	case weekday == time.Monday:
-		// Already past 09:00 on Monday — next occurrence is in 7 days.
-		return today9.AddDate(0, 0, 7)
-	default:
-		// Days until next Monday.
-		daysUntil := (int(time.Monday) - int(weekday) + 7) % 7
-		if daysUntil == 0 {
-			daysUntil = 7
-		}
-		next := time.Date(y, mo, d+daysUntil, 9, 0, 0, 0, loc)
-		return next
-	}
+	year, month, day := t.Date()
+	return time.Date(year, month, day-(weekday-1), 0, 0, 0, 0, time.UTC)
 }
 
 // available returns members who are not on PTO during the given Monday.
@@ -356,6 +611,44 @@ func available(members []*Member, monday time.Time) []*Member {
 	return out
 }
 
+// onPTO returns copies of the members who are away during the given Monday.
+func onPTO(members []*Member, monday time.Time) []*Member {
+	here := available(members, monday)
+	var out []*Member
+	for _, m := range members {
+		if !slices.Contains(here, m) {
+			c := *m
+			out = append(out, &c)
+		}
+	}
+	return out
+}
+
+// matchByAffinity reorders an even-length member list so that consecutive
+// entries form pairs. Each member in turn is matched greedily with the
+// remaining member of lowest affinity, or highest when prefer is set. Members
+// earlier in the input win ties, so a shuffled input keeps pairings varied.
+func matchByAffinity(members []*Member, affinity map[[2]string]float64, prefer bool) []*Member {
+	remaining := append([]*Member(nil), members...)
+	out := make([]*Member, 0, len(members))
+	for len(remaining) >= 2 {
+		first := remaining[0]
+		remaining = remaining[1:]
+
+		best := 0
+		for i := 1; i < len(remaining); i++ {
+			score := affinity[AffinityKey(first.UserID, remaining[i].UserID)]
+			bestScore := affinity[AffinityKey(first.UserID, remaining[best].UserID)]
+			if (prefer && score > bestScore) || (!prefer && score < bestScore) {
+				best = i
+			}
+		}
+		out = append(out, first, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return append(out, remaining...)
+}
+
 // pickSitOut returns the index in available of the member who should sit out.
 // It avoids picking lastSatOutID if there is any other option.
 func pickSitOut(available []*Member, lastSatOutID string) int {
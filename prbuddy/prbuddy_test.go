@@ -4,17 +4,18 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
 
 // newTestBot creates a Bot backed by a temp file. The returned cleanup
 // function removes the file.
-func newTestBot(t *testing.T) (*Bot, func()) {
+func newTestBot(t *testing.T, opts ...Option) (*Bot, func()) {
 	t.Helper()
 	dir := t.TempDir()
 	path := filepath.Join(dir, "prbuddy.json")
-	b, err := New(path, func(string, Result) {})
+	b, err := New(path, func(string, Result) {}, opts...)
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
@@ -194,6 +195,47 @@ func TestClearPTO_NonMember_Error(t *testing.T) {
 	}
 }
 
+func TestSetPTOWithNote(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	_ = b.AddMember("g1", "u1", "Alice")
+
+	leave := time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC)
+	returns := time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC)
+	if err := b.SetPTOWithNote("g1", "u1", leave, returns, "Conference"); err != nil {
+		t.Fatalf("SetPTOWithNote: %v", err)
+	}
+	if got := b.Members("g1")[0].PTO; got == nil || got.Note != "Conference" {
+		t.Errorf("want note Conference, got %+v", got)
+	}
+}
+
+func TestMissedWeeks(t *testing.T) {
+	// Friday Apr 10 until Wednesday Apr 22: misses the weeks of Apr 13 and 20.
+	leave := time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC)
+	returns := time.Date(2026, 4, 22, 0, 0, 0, 0, time.UTC)
+
+	got := MissedWeeks(leave, returns)
+	want := []time.Time{
+		time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("week %d: want %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	// Back on the Monday: that week isn't missed.
+	if got := MissedWeeks(leave, time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)); len(got) != 0 {
+		t.Errorf("want no missed weeks, got %v", got)
+	}
+}
+
 // --- PTO availability -------------------------------------------------------
 
 func TestAvailable_PTOExcludesMember(t *testing.T) {
@@ -332,6 +374,25 @@ func TestGenerate_ThreeMembers_OneSitsOut(t *testing.T) {
 	}
 }
 
+func TestGenerate_ListsMembersOnPTO(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	_ = b.AddMember("g1", "u1", "Alice")
+	_ = b.AddMember("g1", "u2", "Bob")
+	_ = b.AddMember("g1", "u3", "Carol")
+	_ = b.SetPTO("g1", "u3",
+		time.Date(2026, 4, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC))
+
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+	result := b.Generate("g1", monday)
+
+	if len(result.OnPTO) != 1 || result.OnPTO[0].UserID != "u3" {
+		t.Errorf("want u3 on PTO, got %+v", result.OnPTO)
+	}
+}
+
 func TestGenerate_FourMembers_TwoPairs(t *testing.T) {
 	b, cleanup := newTestBot(t)
 	defer cleanup()
@@ -380,10 +441,10 @@ func TestGenerate_OddDevOutRotates(t *testing.T) {
 
 	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
 
-	// Run many times and check that the same person doesn't sit out every time.
+	// Run many weeks and check that the same person doesn't sit out every time.
 	satOutIDs := map[string]int{}
 	for i := 0; i < 30; i++ {
-		result := b.Generate("g1", monday)
+		result := b.Generate("g1", monday.AddDate(0, 0, 7*i))
 		if result.SittingOut != nil {
 			satOutIDs[result.SittingOut.UserID]++
 		}
@@ -408,7 +469,7 @@ func TestGenerate_OddDevOut_NoConsecutiveRepeat(t *testing.T) {
 	// Run many consecutive weeks and verify no one sits out back-to-back.
 	var lastSatOut string
 	for i := 0; i < 20; i++ {
-		result := b.Generate("g1", monday)
+		result := b.Generate("g1", monday.AddDate(0, 0, 7*i))
 		if result.SittingOut == nil {
 			continue
 		}
@@ -419,6 +480,226 @@ func TestGenerate_OddDevOut_NoConsecutiveRepeat(t *testing.T) {
 	}
 }
 
+// --- Seeding ----------------------------------------------------------------
+
+// addTeam adds n members named after letters of the alphabet.
+func addTeam(b *Bot, guildID string, n int) {
+	for i := 0; i < n; i++ {
+		id := string(rune('a' + i))
+		_ = b.AddMember(guildID, id, id)
+	}
+}
+
+// pairIDs flattens a Result into "a-b" strings, plus the sit-out, for
+// comparison.
+func pairIDs(r Result) []string {
+	var out []string
+	for _, p := range r.Pairs {
+		out = append(out, p.A.UserID+"-"+p.B.UserID)
+	}
+	if r.SittingOut != nil {
+		out = append(out, "out:"+r.SittingOut.UserID)
+	}
+	return out
+}
+
+func TestWithSeed_Reproducible(t *testing.T) {
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+
+	b1, cleanup1 := newTestBot(t, WithSeed(42))
+	defer cleanup1()
+	b2, cleanup2 := newTestBot(t, WithSeed(42))
+	defer cleanup2()
+	addTeam(b1, "g1", 8)
+	addTeam(b2, "g1", 8)
+
+	got1, got2 := pairIDs(b1.Generate("g1", monday)), pairIDs(b2.Generate("g1", monday))
+	if !slices.Equal(got1, got2) {
+		t.Errorf("same seed, different pairings: %v vs %v", got1, got2)
+	}
+}
+
+func TestWeekSeeding_SameWeekSamePairs(t *testing.T) {
+	b, cleanup := newTestBot(t, WithWeekSeeding())
+	defer cleanup()
+	addTeam(b, "g1", 7)
+
+	wednesday := time.Date(2026, 4, 8, 0, 0, 0, 0, time.UTC)
+	first := pairIDs(b.Generate("g1", wednesday))
+	again := pairIDs(b.Generate("g1", wednesday.AddDate(0, 0, 1)))
+	if !slices.Equal(first, again) {
+		t.Errorf("regenerating the week changed it: %v vs %v", first, again)
+	}
+
+	rerolled := pairIDs(b.Reroll("g1", wednesday))
+	if slices.Equal(first, rerolled) {
+		t.Errorf("reroll gave the same pairings: %v", rerolled)
+	}
+	if got := pairIDs(b.Generate("g1", wednesday)); !slices.Equal(got, rerolled) {
+		t.Errorf("regenerating after a reroll: want %v, got %v", rerolled, got)
+	}
+}
+
+func TestWeekSeeding_IgnoresRandSource(t *testing.T) {
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+
+	b1, cleanup1 := newTestBot(t, WithWeekSeeding(), WithSeed(1))
+	defer cleanup1()
+	b2, cleanup2 := newTestBot(t, WithWeekSeeding(), WithSeed(2))
+	defer cleanup2()
+	addTeam(b1, "g1", 8)
+	addTeam(b2, "g1", 8)
+
+	got1, got2 := pairIDs(b1.Generate("g1", monday)), pairIDs(b2.Generate("g1", monday))
+	if !slices.Equal(got1, got2) {
+		t.Errorf("week-seeded pairings differ by rand source: %v vs %v", got1, got2)
+	}
+}
+
+func TestWeekSeeding_DiffersByGuildAndWeek(t *testing.T) {
+	b, cleanup := newTestBot(t, WithWeekSeeding())
+	defer cleanup()
+	addTeam(b, "g1", 8)
+	addTeam(b, "g2", 8)
+
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+	g1 := pairIDs(b.Generate("g1", monday))
+	if g2 := pairIDs(b.Generate("g2", monday)); slices.Equal(g1, g2) {
+		t.Errorf("guilds got identical pairings: %v", g1)
+	}
+	if next := pairIDs(b.Generate("g1", monday.AddDate(0, 0, 7))); slices.Equal(g1, next) {
+		t.Errorf("consecutive weeks got identical pairings: %v", g1)
+	}
+}
+
+// --- Pairing modes ----------------------------------------------------------
+
+// addFourWithCliques adds u1..u4 and an affinity signal in which u1/u2 and
+// u3/u4 already work together closely.
+func addFourWithCliques(b *Bot) {
+	_ = b.AddMember("g1", "u1", "Alice")
+	_ = b.AddMember("g1", "u2", "Bob")
+	_ = b.AddMember("g1", "u3", "Carol")
+	_ = b.AddMember("g1", "u4", "Dave")
+	b.SetAffinity(func(guildID string) map[[2]string]float64 {
+		return map[[2]string]float64{
+			AffinityKey("u1", "u2"): 10,
+			AffinityKey("u4", "u3"): 10,
+		}
+	})
+}
+
+func isCliquePair(p Pair) bool {
+	k := AffinityKey(p.A.UserID, p.B.UserID)
+	return k == AffinityKey("u1", "u2") || k == AffinityKey("u3", "u4")
+}
+
+func TestGenerate_StretchModeAvoidsCollaborators(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	addFourWithCliques(b)
+	if err := b.SetPairingMode("g1", ModeStretch); err != nil {
+		t.Fatalf("SetPairingMode: %v", err)
+	}
+
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		for _, p := range b.Reroll("g1", monday).Pairs {
+			if isCliquePair(p) {
+				t.Fatalf("stretch mode paired collaborators %s and %s", p.A.UserID, p.B.UserID)
+			}
+		}
+	}
+}
+
+func TestGenerate_ComfortModePrefersCollaborators(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	addFourWithCliques(b)
+	_ = b.SetPairingMode("g1", ModeComfort)
+
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		for _, p := range b.Reroll("g1", monday).Pairs {
+			if !isCliquePair(p) {
+				t.Fatalf("comfort mode split collaborators: %s and %s", p.A.UserID, p.B.UserID)
+			}
+		}
+	}
+}
+
+func TestGenerate_AffinityComputedWithoutLock(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	addFourWithCliques(b)
+	_ = b.SetPairingMode("g1", ModeStretch)
+	// A slow signal must not hold up the rest of the bot, so it can even
+	// read the team itself.
+	b.SetAffinity(func(guildID string) map[[2]string]float64 {
+		if len(b.Members(guildID)) != 4 {
+			t.Error("affinity signal saw the wrong team")
+		}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Generate("g1", time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC))
+		b.Reroll("g1", time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Generate held the lock while computing affinity")
+	}
+}
+
+func TestSetPairingMode(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	if got := b.PairingMode("g1"); got != ModeRandom {
+		t.Errorf("default mode: want random, got %q", got)
+	}
+	if err := b.SetPairingMode("g1", PairingMode("chaos")); err == nil {
+		t.Error("expected error for unknown pairing mode")
+	}
+}
+
+// --- SetDMOptOut ------------------------------------------------------------
+
+func TestSetDMOptOut(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	_ = b.AddMember("g1", "u1", "Alice")
+	if err := b.SetDMOptOut("g1", "u1", true); err != nil {
+		t.Fatalf("SetDMOptOut: %v", err)
+	}
+	if !b.Members("g1")[0].DMOptOut {
+		t.Error("expected DMOptOut to be set")
+	}
+	if err := b.SetDMOptOut("g1", "u1", false); err != nil {
+		t.Fatalf("SetDMOptOut: %v", err)
+	}
+	if b.Members("g1")[0].DMOptOut {
+		t.Error("expected DMOptOut to be cleared")
+	}
+}
+
+func TestSetDMOptOut_NonMember_Error(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	if err := b.SetDMOptOut("g1", "ghost", true); err == nil {
+		t.Error("expected error for non-member")
+	}
+}
+
 // --- Persistence ------------------------------------------------------------
 
 func TestPersistence_RoundTrip(t *testing.T) {
@@ -522,6 +803,15 @@ func TestWeekMonday(t *testing.T) {
 		},
 	}
 
+	// Dates are taken in the time's own location, not UTC.
+	if sydney, err := time.LoadLocation("Australia/Sydney"); err == nil {
+		cases = append(cases, struct{ in, want time.Time }{
+			// Monday 09:00 in Sydney, Sunday 23:00 UTC
+			in:   time.Date(2026, 4, 13, 9, 0, 0, 0, sydney),
+			want: time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC),
+		})
+	}
+
 	for _, tc := range cases {
 		got := weekMonday(tc.in)
 		if !got.Equal(tc.want) {
@@ -530,30 +820,39 @@ func TestWeekMonday(t *testing.T) {
 	}
 }
 
-// --- nextMonday9am helper ---------------------------------------------------
+// --- Default pairing schedule -----------------------------------------------
+
+func nextPairingRun(t *testing.T, now time.Time) time.Time {
+	t.Helper()
+	spec, err := ParseSpec(DefaultPairingSpec)
+	if err != nil {
+		t.Fatalf("ParseSpec: %v", err)
+	}
+	return spec.Next(now)
+}
 
-func TestNextMonday9am_BeforeMonday(t *testing.T) {
+func TestDefaultPairingSpec_BeforeMonday(t *testing.T) {
 	// Tuesday — next Monday is 6 days away.
 	now := time.Date(2026, 4, 7, 10, 0, 0, 0, time.Local)
-	next := nextMonday9am(now)
+	next := nextPairingRun(t, now)
 	want := time.Date(2026, 4, 13, 9, 0, 0, 0, time.Local)
 	if !next.Equal(want) {
 		t.Errorf("got %v, want %v", next, want)
 	}
 }
 
-func TestNextMonday9am_MondayBefore9(t *testing.T) {
+func TestDefaultPairingSpec_MondayBefore9(t *testing.T) {
 	now := time.Date(2026, 4, 6, 8, 0, 0, 0, time.Local)
-	next := nextMonday9am(now)
+	next := nextPairingRun(t, now)
 	want := time.Date(2026, 4, 6, 9, 0, 0, 0, time.Local)
 	if !next.Equal(want) {
 		t.Errorf("got %v, want %v", next, want)
 	}
 }
 
-func TestNextMonday9am_MondayAfter9(t *testing.T) {
+func TestDefaultPairingSpec_MondayAfter9(t *testing.T) {
 	now := time.Date(2026, 4, 6, 10, 0, 0, 0, time.Local)
-	next := nextMonday9am(now)
+	next := nextPairingRun(t, now)
 	want := time.Date(2026, 4, 13, 9, 0, 0, 0, time.Local)
 	if !next.Equal(want) {
 		t.Errorf("got %v, want %v", next, want)
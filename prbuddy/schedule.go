@@ -0,0 +1,444 @@
+package prbuddy
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Scheduled job names.
+const (
+	// JobPairings generates and posts the week's pairings.
+	JobPairings = "pairings"
+	// JobReminder pings members who haven't checked in on their pairing.
+	JobReminder = "reminder"
+)
+
+// DefaultPairingSpec runs pairings every Monday at 09:00.
+const DefaultPairingSpec = "0 9 * * 1"
+
+// catchUpWindow is how late a job may still run after it was due, e.g. when
+// the bot was down at the time. Later runs are skipped rather than posting
+// Monday's pairings on a Thursday.
+const catchUpWindow = 6 * time.Hour
+
+// maxSchedulerSleep bounds how long the scheduler waits between checks, so
+// guilds that appear in the meantime get their jobs planned.
+const maxSchedulerSleep = time.Hour
+
+// Clock tells the scheduler the time and lets it wait. Tests substitute one
+// they control.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the real wall clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Reminder is a mid-week check-in on the current pairing week.
+type Reminder struct {
+	// Week is the Monday that opens the pairing week.
+	Week time.Time
+	// Pairs are the pairs with at least one pending member.
+	Pairs []Pair
+	// Pending lists the user IDs of paired members who have neither
+	// acknowledged their pairing nor marked their review done.
+	Pending []string
+}
+
+// ReminderFunc is called with a guild's reminder when it falls due and at
+// least one member is pending. It is the caller's responsibility to format
+// and send the Discord message.
+type ReminderFunc func(guildID string, reminder Reminder)
+
+// SetReminderFunc installs the handler for mid-week reminders. Guilds get no
+// reminders until one is set.
+func (b *Bot) SetReminderFunc(f ReminderFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remind = f
+}
+
+// RegisterJob adds a job the scheduler runs for every guild. defaultSpec is a
+// cron expression (see ParseSpec), or "" for a job that is off until a guild
+// sets its own schedule with SetSchedule.
+func (b *Bot) RegisterJob(name, defaultSpec string, run func(guildID string, now time.Time)) error {
+	if defaultSpec != "" {
+		if _, err := ParseSpec(defaultSpec); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.job(name) != nil {
+		return fmt.Errorf("job %q is already registered", name)
+	}
+	b.jobs = append(b.jobs, &job{name: name, defaultSpec: defaultSpec, run: run})
+	b.wake()
+	return nil
+}
+
+// SetSchedule overrides when a job runs for the guild. spec is a cron
+// expression evaluated in the guild's time zone, or "" to go back to the
+// job's default.
+func (b *Bot) SetSchedule(guildID, jobName, spec string) error {
+	var parsed Spec
+	if spec != "" {
+		var err error
+		if parsed, err = ParseSpec(spec); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.job(jobName) == nil {
+		return fmt.Errorf("unknown job %q", jobName)
+	}
+	g := b.guild(guildID)
+	if spec != "" && parsed.Next(b.clock.Now().In(b.location(g))).IsZero() {
+		return fmt.Errorf("cron spec %q never runs", spec)
+	}
+	if spec == "" {
+		delete(g.Schedules, jobName)
+	} else {
+		if g.Schedules == nil {
+			g.Schedules = make(map[string]string)
+		}
+		g.Schedules[jobName] = spec
+	}
+	delete(g.NextRuns, jobName)
+	if err := b.save(); err != nil {
+		return err
+	}
+	b.wake()
+	return nil
+}
+
+// Schedule returns the cron spec a job runs on for the guild, and false if
+// the job is off.
+func (b *Bot) Schedule(guildID, jobName string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	j := b.job(jobName)
+	if j == nil {
+		return "", false
+	}
+	spec, ok := b.guild(guildID).spec(j)
+	if !ok {
+		return "", false
+	}
+	return spec.String(), true
+}
+
+// SetTimeZone sets the IANA time zone, e.g. "Europe/London", that the
+// guild's schedules are evaluated in. "" uses the Bot's default, local time
+// unless set with WithLocation.
+func (b *Bot) SetTimeZone(guildID, name string) error {
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("unknown time zone %q", name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	g.TimeZone = name
+	g.NextRuns = nil
+	if err := b.save(); err != nil {
+		return err
+	}
+	b.wake()
+	return nil
+}
+
+// TimeZone returns the guild's time zone as set with SetTimeZone, or "" if
+// it uses the Bot's default.
+func (b *Bot) TimeZone(guildID string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.guild(guildID).TimeZone
+}
+
+// NextRun returns when a job next runs for the guild, and false if it is off.
+func (b *Bot) NextRun(guildID, jobName string) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	j := b.job(jobName)
+	if j == nil {
+		return time.Time{}, false
+	}
+	g := b.guild(guildID)
+	spec, ok := g.spec(j)
+	if !ok {
+		return time.Time{}, false
+	}
+	if next, ok := g.NextRuns[jobName]; ok {
+		return next, true
+	}
+	return spec.Next(b.clock.Now().In(b.location(g))), true
+}
+
+// SchedulerHeartbeat returns when the scheduler last woke to check for due
+// jobs, or the zero time if it isn't running.
+func (b *Bot) SchedulerHeartbeat() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastTick
+}
+
+// SchedulerAlive reports whether the scheduler is running and has woken
+// recently. It sleeps for at most maxSchedulerSleep, so a longer silence
+// means it is stuck, most likely in a job.
+func (b *Bot) SchedulerAlive() bool {
+	last := b.SchedulerHeartbeat()
+	return !last.IsZero() && b.clock.Now().Sub(last) <= maxSchedulerSleep+time.Minute
+}
+
+// SetReminderDay schedules the guild's mid-week reminder for 09:00 on the
+// given weekday. Monday is refused, as that is when pairings are posted.
+func (b *Bot) SetReminderDay(guildID string, day time.Weekday) error {
+	if day == time.Monday {
+		return fmt.Errorf("reminders can't be on Monday, when pairings are posted")
+	}
+	return b.SetSchedule(guildID, JobReminder, fmt.Sprintf("0 9 * * %d", day))
+}
+
+// ClearReminder turns off the guild's mid-week reminder.
+func (b *Bot) ClearReminder(guildID string) error {
+	return b.SetSchedule(guildID, JobReminder, "")
+}
+
+// Reminder returns the check-in for the pairing week containing now. It
+// reports false if that week hasn't been generated or nobody is pending.
+func (b *Bot) Reminder(guildID string, now time.Time) (Reminder, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	w, err := g.currentWeek(b.weekOf(guildID, now))
+	if err != nil {
+		return Reminder{}, false
+	}
+
+	result := g.result()
+	reminder := Reminder{Week: result.Week}
+	pending := func(userID string) bool {
+		return !slices.Contains(w.Acked, userID) && !slices.Contains(w.Done, userID)
+	}
+	for _, p := range result.Pairs {
+		a, b := pending(p.A.UserID), pending(p.B.UserID)
+		if a {
+			reminder.Pending = append(reminder.Pending, p.A.UserID)
+		}
+		if b {
+			reminder.Pending = append(reminder.Pending, p.B.UserID)
+		}
+		if a || b {
+			reminder.Pairs = append(reminder.Pairs, p)
+		}
+	}
+	return reminder, len(reminder.Pending) > 0
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// job is a task the scheduler runs for each guild.
+type job struct {
+	name        string
+	defaultSpec string
+	run         func(guildID string, now time.Time)
+}
+
+// registerJobs registers the built-in pairing and reminder jobs.
+func (b *Bot) registerJobs() {
+	_ = b.RegisterJob(JobPairings, b.pairSpec, func(guildID string, now time.Time) {
+		b.postFunc(guildID, b.Generate(guildID, now))
+	})
+	_ = b.RegisterJob(JobReminder, "", func(guildID string, now time.Time) {
+		reminder, ok := b.Reminder(guildID, now)
+		b.mu.Lock()
+		remind := b.remind
+		b.mu.Unlock()
+		if ok && remind != nil {
+			remind(guildID, reminder)
+		}
+	})
+}
+
+// job returns the registered job with the given name, or nil.
+// Caller must hold b.mu.
+func (b *Bot) job(name string) *job {
+	for _, j := range b.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// spec returns the guild's cron spec for a job, and false if the job is off.
+func (g *store) spec(j *job) (Spec, bool) {
+	expr := j.defaultSpec
+	if override, ok := g.Schedules[j.name]; ok {
+		expr = override
+	}
+	if expr == "" {
+		return Spec{}, false
+	}
+	spec, err := ParseSpec(expr)
+	if err != nil {
+		// Specs are validated before they are stored; ignore a hand-edited
+		// bad one rather than failing every tick.
+		return Spec{}, false
+	}
+	return spec, true
+}
+
+// location returns the guild's time zone, falling back to the Bot's default.
+func (b *Bot) location(g *store) *time.Location {
+	if g.TimeZone == "" {
+		return b.loc
+	}
+	loc, err := time.LoadLocation(g.TimeZone)
+	if err != nil {
+		return b.loc
+	}
+	return loc
+}
+
+// weekOf returns the Monday of the pairing week containing t, going by the
+// date in the guild's time zone. The caller must hold b.mu.
+func (b *Bot) weekOf(guildID string, t time.Time) time.Time {
+	return weekMonday(t.In(b.location(b.guild(guildID))))
+}
+
+// runDue runs every job that has fallen due by now and plans each job's next
+// run. Jobs with no planned run yet are planned without running, so a fresh
+// guild doesn't fire everything at once. It returns the jobs it ran as
+// "guildID/job", in order.
+func (b *Bot) runDue(now time.Time) []string {
+	type due struct {
+		guildID string
+		job     *job
+	}
+
+	b.mu.Lock()
+	guildIDs := make([]string, 0, len(b.guilds))
+	for id := range b.guilds {
+		guildIDs = append(guildIDs, id)
+	}
+	slices.Sort(guildIDs)
+
+	var run []due
+	changed := false
+	for _, guildID := range guildIDs {
+		g := b.guilds[guildID]
+		for _, j := range b.jobs {
+			spec, ok := g.spec(j)
+			if !ok {
+				if _, planned := g.NextRuns[j.name]; planned {
+					delete(g.NextRuns, j.name)
+					changed = true
+				}
+				continue
+			}
+
+			next, planned := g.NextRuns[j.name]
+			if planned && (next.IsZero() || next.After(now)) {
+				continue // a zero next run means the spec never matches
+			}
+			if planned {
+				if now.Sub(next) <= catchUpWindow {
+					run = append(run, due{guildID, j})
+				} else {
+					b.log.Warn("prbuddy: skipping missed job", "guild", guildID, "job", j.name, "due", next)
+				}
+			}
+			if g.NextRuns == nil {
+				g.NextRuns = make(map[string]time.Time)
+			}
+			g.NextRuns[j.name] = spec.Next(now.In(b.location(g)))
+			changed = true
+		}
+	}
+	if changed {
+		// Persist next runs so restarts don't skip or repeat jobs.
+		if err := b.save(); err != nil {
+			b.log.Error("prbuddy: failed to save schedule", "err", err)
+		}
+	}
+	b.mu.Unlock()
+
+	ran := make([]string, 0, len(run))
+	for _, d := range run {
+		b.log.Info("prbuddy: running job", "guild", d.guildID, "job", d.job.name)
+		d.job.run(d.guildID, now)
+		ran = append(ran, d.guildID+"/"+d.job.name)
+	}
+	return ran
+}
+
+// nextWake returns how long the scheduler should wait after now before its
+// next check.
+func (b *Bot) nextWake(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wait := maxSchedulerSleep
+	for _, g := range b.guilds {
+		for _, next := range g.NextRuns {
+			if next.IsZero() {
+				continue // the spec never matches
+			}
+			if d := next.Sub(now); d < wait {
+				wait = d
+			}
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// runScheduler runs due jobs, then sleeps until the next one or until a
+// schedule changes.
+func (b *Bot) runScheduler() {
+	defer func() {
+		b.mu.Lock()
+		b.lastTick = time.Time{}
+		b.mu.Unlock()
+	}()
+	for {
+		now := b.clock.Now()
+		b.mu.Lock()
+		b.lastTick = now
+		b.mu.Unlock()
+		b.runDue(now)
+
+		select {
+		case <-b.stopCh:
+			return
+		case <-b.wakeCh:
+		case <-b.clock.After(b.nextWake(now)):
+		}
+	}
+}
+
+// wake asks the scheduler to re-plan after a schedule change.
+func (b *Bot) wake() {
+	select {
+	case b.wakeCh <- struct{}{}:
+	default:
+	}
+}
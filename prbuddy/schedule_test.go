@@ -0,0 +1,475 @@
+package prbuddy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- Reminder ---------------------------------------------------------------
+
+func TestReminder_Pending(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 4)
+	p1, p2 := generated.Pairs[0], generated.Pairs[1]
+	_, _ = b.MarkAcked("g1", p1.A.UserID, generated.Week)
+	_, _ = b.MarkDone("g1", p1.B.UserID, generated.Week)
+	_, _ = b.MarkAcked("g1", p2.A.UserID, generated.Week)
+
+	thursday := generated.Week.AddDate(0, 0, 3).Add(9 * time.Hour)
+	reminder, ok := b.Reminder("g1", thursday)
+	if !ok {
+		t.Fatal("expected a reminder")
+	}
+	if !slices.Equal(reminder.Pending, []string{p2.B.UserID}) {
+		t.Errorf("want only %s pending, got %v", p2.B.UserID, reminder.Pending)
+	}
+	if len(reminder.Pairs) != 1 {
+		t.Errorf("want 1 pair pending, got %d", len(reminder.Pairs))
+	}
+}
+
+func TestReminder_NobodyPending(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 2)
+	_, _ = b.MarkDone("g1", generated.Pairs[0].A.UserID, generated.Week)
+	_, _ = b.MarkAcked("g1", generated.Pairs[0].B.UserID, generated.Week)
+
+	if _, ok := b.Reminder("g1", generated.Week.AddDate(0, 0, 3)); ok {
+		t.Error("expected no reminder when everyone has checked in")
+	}
+}
+
+func TestReminder_StaleWeek(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 2)
+	if _, ok := b.Reminder("g1", generated.Week.AddDate(0, 0, 10)); ok {
+		t.Error("expected no reminder for a week that wasn't generated")
+	}
+}
+
+func TestSwap_ForgetsAcknowledgements(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 4)
+	user := generated.Pairs[0].A.UserID
+	_, _ = b.MarkAcked("g1", user, generated.Week)
+	_, _ = b.Swap("g1", user, generated.Week)
+
+	reminder, _ := b.Reminder("g1", generated.Week.AddDate(0, 0, 3))
+	if !slices.Contains(reminder.Pending, user) {
+		t.Errorf("want %s pending after swapping, got %v", user, reminder.Pending)
+	}
+}
+
+// --- SetReminderDay / ClearReminder -----------------------------------------
+
+func TestSetReminderDay(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	if _, ok := b.Schedule("g1", JobReminder); ok {
+		t.Error("expected no reminder by default")
+	}
+	if err := b.SetReminderDay("g1", time.Monday); err == nil {
+		t.Error("expected error for a Monday reminder")
+	}
+	if err := b.SetReminderDay("g1", time.Thursday); err != nil {
+		t.Fatalf("SetReminderDay: %v", err)
+	}
+	if spec, ok := b.Schedule("g1", JobReminder); !ok || spec != "0 9 * * 4" {
+		t.Errorf("want Thursdays at 09:00, got %q %v", spec, ok)
+	}
+	if err := b.ClearReminder("g1"); err != nil {
+		t.Fatalf("ClearReminder: %v", err)
+	}
+	if _, ok := b.Schedule("g1", JobReminder); ok {
+		t.Error("expected reminder cleared")
+	}
+}
+
+// --- SetSchedule / SetTimeZone ----------------------------------------------
+
+func TestSetSchedule(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	if spec, _ := b.Schedule("g1", JobPairings); spec != DefaultPairingSpec {
+		t.Errorf("default pairing spec: got %q", spec)
+	}
+	if err := b.SetSchedule("g1", JobPairings, "30 8 * * 2"); err != nil {
+		t.Fatalf("SetSchedule: %v", err)
+	}
+	if spec, _ := b.Schedule("g1", JobPairings); spec != "30 8 * * 2" {
+		t.Errorf("override: got %q", spec)
+	}
+	if spec, _ := b.Schedule("g2", JobPairings); spec != DefaultPairingSpec {
+		t.Errorf("other guild: got %q", spec)
+	}
+	if err := b.SetSchedule("g1", JobPairings, ""); err != nil {
+		t.Fatalf("SetSchedule reset: %v", err)
+	}
+	if spec, _ := b.Schedule("g1", JobPairings); spec != DefaultPairingSpec {
+		t.Errorf("after reset: got %q", spec)
+	}
+
+	if err := b.SetSchedule("g1", JobPairings, "every monday"); err == nil {
+		t.Error("expected error for a bad spec")
+	}
+	if err := b.SetSchedule("g1", "nope", "0 9 * * 1"); err == nil {
+		t.Error("expected error for an unknown job")
+	}
+	if err := b.SetSchedule("g1", JobPairings, "0 9 30 2 *"); err == nil {
+		t.Error("expected error for a spec that never runs (Feb 30)")
+	}
+}
+
+func TestSetTimeZone(t *testing.T) {
+	b, cleanup := newTestBot(t, WithClock(&fakeClock{now: time.Date(2026, 4, 8, 0, 0, 0, 0, time.UTC)}))
+	defer cleanup()
+
+	if err := b.SetTimeZone("g1", "Mars/Olympus_Mons"); err == nil {
+		t.Error("expected error for unknown time zone")
+	}
+	if err := b.SetTimeZone("g1", "Asia/Tokyo"); err != nil {
+		t.Fatalf("SetTimeZone: %v", err)
+	}
+	if got := b.TimeZone("g1"); got != "Asia/Tokyo" {
+		t.Errorf("TimeZone: got %q", got)
+	}
+
+	next, ok := b.NextRun("g1", JobPairings)
+	want := time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC) // 09:00 in Tokyo
+	if !ok || !next.Equal(want) {
+		t.Errorf("NextRun: got %v, want %v", next, want)
+	}
+}
+
+func TestWithPairingSchedule(t *testing.T) {
+	b, cleanup := newTestBot(t, WithPairingSchedule("30 10 * * 2"))
+	defer cleanup()
+
+	if spec, _ := b.Schedule("g1", JobPairings); spec != "30 10 * * 2" {
+		t.Errorf("default pairing spec: got %q", spec)
+	}
+
+	b2, cleanup2 := newTestBot(t, WithPairingSchedule("whenever"))
+	defer cleanup2()
+
+	if spec, _ := b2.Schedule("g1", JobPairings); spec != DefaultPairingSpec {
+		t.Errorf("invalid spec should keep the default, got %q", spec)
+	}
+
+	b3, cleanup3 := newTestBot(t, WithPairingSchedule("0 9 30 2 *"))
+	defer cleanup3()
+
+	if spec, _ := b3.Schedule("g1", JobPairings); spec != DefaultPairingSpec {
+		t.Errorf("a spec that never runs should keep the default, got %q", spec)
+	}
+}
+
+func TestWithLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no tz data")
+	}
+	b, cleanup := newTestBot(t,
+		WithClock(&fakeClock{now: time.Date(2026, 4, 8, 0, 0, 0, 0, time.UTC)}),
+		WithLocation(tokyo))
+	defer cleanup()
+
+	next, ok := b.NextRun("g1", JobPairings)
+	want := time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC) // 09:00 in Tokyo
+	if !ok || !next.Equal(want) {
+		t.Errorf("NextRun: got %v, want %v", next, want)
+	}
+
+	// A guild's own time zone wins over the default.
+	if err := b.SetTimeZone("g2", "UTC"); err != nil {
+		t.Fatalf("SetTimeZone: %v", err)
+	}
+	next, _ = b.NextRun("g2", JobPairings)
+	if want := time.Date(2026, 4, 13, 9, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextRun with guild zone: got %v, want %v", next, want)
+	}
+}
+
+// --- Scheduler --------------------------------------------------------------
+
+// fakeClock is a Clock whose time only moves when the test says so.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(time.Duration) <-chan time.Time { return make(chan time.Time) }
+
+func TestRunDue(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	_ = b.AddMember("g1", "u1", "Alice")
+	_ = b.AddMember("g2", "u2", "Bob")
+	_ = b.SetReminderDay("g1", time.Thursday)
+
+	wednesday := time.Date(2026, 4, 8, 10, 0, 0, 0, time.Local)
+	thursday9 := time.Date(2026, 4, 9, 9, 0, 0, 0, time.Local)
+	monday9 := time.Date(2026, 4, 13, 9, 0, 0, 0, time.Local)
+
+	// The first check only plans runs.
+	if got := b.runDue(wednesday); len(got) != 0 {
+		t.Errorf("first check ran %v", got)
+	}
+	if got := b.runDue(thursday9.Add(-time.Minute)); len(got) != 0 {
+		t.Errorf("nothing should be due before 09:00 Thursday, got %v", got)
+	}
+	if got := b.runDue(thursday9); !slices.Equal(got, []string{"g1/reminder"}) {
+		t.Errorf("Thursday: got %v", got)
+	}
+	if got := b.runDue(thursday9.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("reminder ran twice: %v", got)
+	}
+	if got := b.runDue(monday9); !slices.Equal(got, []string{"g1/pairings", "g2/pairings"}) {
+		t.Errorf("Monday: got %v", got)
+	}
+}
+
+func TestRunDue_PairingWeekInGuildTimeZone(t *testing.T) {
+	for _, zone := range []string{"Australia/Sydney", "Pacific/Auckland"} {
+		t.Run(zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(zone)
+			if err != nil {
+				t.Skip("no tz data")
+			}
+
+			var posted []Result
+			b, err := New(t.TempDir()+"/prbuddy.json", func(_ string, r Result) { posted = append(posted, r) },
+				WithWeekSeeding())
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			_ = b.AddMember("g1", "u1", "Alice")
+			_ = b.AddMember("g1", "u2", "Bob")
+			if err := b.SetTimeZone("g1", zone); err != nil {
+				t.Fatalf("SetTimeZone: %v", err)
+			}
+
+			// 09:00 Monday there is still Sunday in UTC.
+			monday9 := time.Date(2026, 4, 13, 9, 0, 0, 0, loc)
+			if monday9.UTC().Weekday() != time.Sunday {
+				t.Fatalf("test setup: %v is not Sunday in UTC", monday9.UTC())
+			}
+			b.runDue(monday9.AddDate(0, 0, -3))
+			if got := b.runDue(monday9); !slices.Equal(got, []string{"g1/pairings"}) {
+				t.Fatalf("Monday: got %v", got)
+			}
+
+			want := time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)
+			if len(posted) != 1 || !posted[0].Week.Equal(want) {
+				t.Fatalf("want pairings for the week of %v, got %+v", want, posted)
+			}
+			if r, ok := b.Reminder("g1", monday9.Add(time.Hour)); !ok || !r.Week.Equal(want) {
+				t.Errorf("Reminder: got %+v, %v; want the week of %v", r, ok, want)
+			}
+		})
+	}
+}
+
+func TestRunDue_PersistsNextRuns(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	_ = b.AddMember("g1", "u1", "Alice")
+	friday := time.Date(2026, 4, 10, 12, 0, 0, 0, time.Local)
+	b.runDue(friday)
+
+	// A restart over the weekend still runs Monday's pairings once.
+	reloaded, err := New(b.path, func(string, Result) {})
+	if err != nil {
+		t.Fatalf("New (reload): %v", err)
+	}
+	monday := time.Date(2026, 4, 13, 9, 5, 0, 0, time.Local)
+	if got := reloaded.runDue(monday); !slices.Equal(got, []string{"g1/pairings"}) {
+		t.Errorf("after restart: got %v", got)
+	}
+}
+
+func TestRunDue_SkipsLongMissedRuns(t *testing.T) {
+	var logs bytes.Buffer
+	b, cleanup := newTestBot(t, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	defer cleanup()
+
+	_ = b.AddMember("g1", "u1", "Alice")
+	b.runDue(time.Date(2026, 4, 10, 12, 0, 0, 0, time.Local))
+
+	thursday := time.Date(2026, 4, 16, 12, 0, 0, 0, time.Local)
+	if got := b.runDue(thursday); len(got) != 0 {
+		t.Errorf("want Monday's run skipped on Thursday, got %v", got)
+	}
+	if next, _ := b.NextRun("g1", JobPairings); !next.Equal(time.Date(2026, 4, 20, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("want the next Monday planned, got %v", next)
+	}
+	if !strings.Contains(logs.String(), "skipping missed job") || !strings.Contains(logs.String(), "job=pairings") {
+		t.Errorf("want the skipped run logged, got %q", logs.String())
+	}
+}
+
+func TestRunDue_SpecThatNeverRuns(t *testing.T) {
+	var logs bytes.Buffer
+	b, cleanup := newTestBot(t, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	defer cleanup()
+
+	// Feb 30 parses but never comes round.
+	if err := b.RegisterJob("never", "0 9 30 2 *", func(string, time.Time) {
+		t.Error("job ran")
+	}); err != nil {
+		t.Fatalf("RegisterJob: %v", err)
+	}
+	_ = b.AddMember("g1", "u1", "Alice")
+
+	start := time.Date(2026, 4, 8, 9, 0, 0, 0, time.Local)
+	for day := range 3 {
+		for _, ran := range b.runDue(start.AddDate(0, 0, day)) {
+			if ran == "g1/never" {
+				t.Errorf("day %d: ran %s", day, ran)
+			}
+		}
+	}
+	if strings.Contains(logs.String(), "job=never") && strings.Contains(logs.String(), "skipping missed job") {
+		t.Errorf("want nothing logged for a spec that never runs, got %q", logs.String())
+	}
+}
+
+func TestRegisterJob(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	var ran []string
+	err := b.RegisterJob("standup", "0 10 * * 1-5", func(guildID string, _ time.Time) {
+		ran = append(ran, guildID)
+	})
+	if err != nil {
+		t.Fatalf("RegisterJob: %v", err)
+	}
+	if err := b.RegisterJob("standup", "", nil); err == nil {
+		t.Error("expected error registering a job twice")
+	}
+
+	_ = b.AddMember("g1", "u1", "Alice")
+	b.runDue(time.Date(2026, 4, 8, 9, 0, 0, 0, time.Local))
+	b.runDue(time.Date(2026, 4, 8, 10, 0, 0, 0, time.Local))
+	if !slices.Equal(ran, []string{"g1"}) {
+		t.Errorf("want standup run for g1, got %v", ran)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the scheduler")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerHeartbeat(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 4, 8, 12, 0, 0, 0, time.UTC)}
+	b, cleanup := newTestBot(t, WithClock(clock))
+	defer cleanup()
+
+	if b.SchedulerAlive() {
+		t.Error("scheduler should not be alive before it starts")
+	}
+
+	b.StartScheduler()
+	waitFor(t, func() bool { return !b.SchedulerHeartbeat().IsZero() })
+	if !b.SchedulerAlive() {
+		t.Error("scheduler should be alive once it has woken")
+	}
+
+	// The fake clock never fires, so the scheduler looks stuck.
+	clock.now = clock.now.Add(2 * time.Hour)
+	if b.SchedulerAlive() {
+		t.Error("scheduler silent for two hours should not be alive")
+	}
+
+	if err := b.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if !b.SchedulerHeartbeat().IsZero() {
+		t.Error("heartbeat should be cleared once Stop returns")
+	}
+}
+
+func TestStop_WaitsForRunningJob(t *testing.T) {
+	start := time.Date(2026, 4, 8, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	b, cleanup := newTestBot(t, WithClock(clock))
+	defer cleanup()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	err := b.RegisterJob("slow", "* * * * *", func(string, time.Time) {
+		close(started)
+		<-release
+	})
+	if err != nil {
+		t.Fatalf("RegisterJob: %v", err)
+	}
+	_ = b.AddMember("g1", "u1", "Alice")
+	b.runDue(start) // plan the first run a minute from now
+	clock.now = start.Add(time.Minute)
+
+	b.StartScheduler()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Stop(ctx); err == nil {
+		t.Error("Stop should time out while the job is running")
+	}
+
+	close(release)
+	if err := b.Stop(context.Background()); err != nil {
+		t.Errorf("Stop after the job finished: %v", err)
+	}
+}
+
+func TestStop_NeverStarted(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	if err := b.Stop(context.Background()); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
+
+func TestNextWake(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	wednesday := time.Date(2026, 4, 8, 10, 0, 0, 0, time.Local)
+	if got := b.nextWake(wednesday); got != maxSchedulerSleep {
+		t.Errorf("no guilds: got %v, want %v", got, maxSchedulerSleep)
+	}
+
+	_ = b.SetReminderDay("g1", time.Wednesday)
+	b.runDue(wednesday.Add(-2 * time.Hour))
+	if got := b.nextWake(wednesday.Add(-2 * time.Hour)); got != time.Hour {
+		t.Errorf("with reminder at 09:00: got %v, want 1h", got)
+	}
+}
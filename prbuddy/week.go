@@ -0,0 +1,223 @@
+package prbuddy
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// week is the persisted form of a generated Result, kept so members can
+// adjust their own pairing without reshuffling everybody.
+type week struct {
+	Monday     time.Time   `json:"monday"`
+	Pairs      [][2]string `json:"pairs"`
+	SittingOut string      `json:"sitting_out,omitempty"`
+	Acked      []string    `json:"acked,omitempty"`
+	Done       []string    `json:"done,omitempty"`
+	// Rerolls counts how many times the week has been rerolled.
+	Rerolls int `json:"rerolls,omitempty"`
+	// PrevSatOutID is who sat out the week before, so regenerating this week
+	// rotates the same way.
+	PrevSatOutID string `json:"prev_sat_out_id,omitempty"`
+}
+
+// newWeek captures a freshly generated Result.
+func newWeek(result Result) *week {
+	w := &week{Monday: result.Week}
+	for _, p := range result.Pairs {
+		w.Pairs = append(w.Pairs, [2]string{p.A.UserID, p.B.UserID})
+	}
+	if result.SittingOut != nil {
+		w.SittingOut = result.SittingOut.UserID
+	}
+	return w
+}
+
+// CurrentWeek returns the guild's most recently generated pairings, including
+// any adjustments members have made since. It reports false if none have been
+// generated.
+func (b *Bot) CurrentWeek(guildID string) (Result, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	if g.Week == nil {
+		return Result{}, false
+	}
+	return g.result(), true
+}
+
+// Swap gives a paired member a different buddy for the week starting monday
+// by trading places with a random member of another pair.
+func (b *Bot) Swap(guildID, userID string, monday time.Time) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	w, err := g.currentWeek(monday)
+	if err != nil {
+		return Result{}, err
+	}
+	idx, side := w.pairOf(userID)
+	if idx < 0 {
+		return Result{}, fmt.Errorf("you aren't paired this week")
+	}
+	if len(w.Pairs) < 2 {
+		return Result{}, fmt.Errorf("there's no other pair to swap with")
+	}
+
+	other := b.randSrc.Intn(len(w.Pairs) - 1)
+	if other >= idx {
+		other++
+	}
+	otherSide := b.randSrc.Intn(2)
+	w.Pairs[idx][side], w.Pairs[other][otherSide] = w.Pairs[other][otherSide], w.Pairs[idx][side]
+	// Swapping changes who is reviewing whom, so neither pair has
+	// acknowledged or finished yet.
+	w.forget(w.Pairs[idx][0], w.Pairs[idx][1], w.Pairs[other][0], w.Pairs[other][1])
+
+	if err := b.save(); err != nil {
+		return Result{}, err
+	}
+	return g.result(), nil
+}
+
+// MarkOut takes a member out of the week starting monday. Their buddy is
+// paired with whoever was sitting out, or sits out themselves if nobody was.
+func (b *Bot) MarkOut(guildID, userID string, monday time.Time) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	w, err := g.currentWeek(monday)
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch idx, side := w.pairOf(userID); {
+	case w.SittingOut == userID:
+		w.SittingOut = ""
+	case idx < 0:
+		return Result{}, fmt.Errorf("you aren't in this week's pairings")
+	default:
+		buddy := w.Pairs[idx][1-side]
+		w.forget(buddy)
+		if w.SittingOut != "" {
+			w.Pairs[idx] = [2]string{buddy, w.SittingOut}
+			w.SittingOut = ""
+		} else {
+			w.Pairs = slices.Delete(w.Pairs, idx, idx+1)
+			w.SittingOut = buddy
+		}
+	}
+	w.forget(userID)
+
+	if err := b.save(); err != nil {
+		return Result{}, err
+	}
+	return g.result(), nil
+}
+
+// MarkAcked records that a paired member has seen their pairing for the week
+// starting monday, so the mid-week reminder leaves them alone.
+func (b *Bot) MarkAcked(guildID, userID string, monday time.Time) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	w, err := g.currentWeek(monday)
+	if err != nil {
+		return Result{}, err
+	}
+	if idx, _ := w.pairOf(userID); idx < 0 {
+		return Result{}, fmt.Errorf("you aren't paired this week")
+	}
+	if !slices.Contains(w.Acked, userID) {
+		w.Acked = append(w.Acked, userID)
+	}
+
+	if err := b.save(); err != nil {
+		return Result{}, err
+	}
+	return g.result(), nil
+}
+
+// MarkDone records that a paired member has finished reviewing for the week
+// starting monday.
+func (b *Bot) MarkDone(guildID, userID string, monday time.Time) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.guild(guildID)
+	w, err := g.currentWeek(monday)
+	if err != nil {
+		return Result{}, err
+	}
+	if idx, _ := w.pairOf(userID); idx < 0 {
+		return Result{}, fmt.Errorf("you aren't paired this week")
+	}
+	if !slices.Contains(w.Done, userID) {
+		w.Done = append(w.Done, userID)
+	}
+
+	if err := b.save(); err != nil {
+		return Result{}, err
+	}
+	return g.result(), nil
+}
+
+// --- internal helpers -------------------------------------------------------
+
+// currentWeek returns the stored week if it starts on monday, so that buttons
+// on an old pairing message can't change a newer one.
+func (g *store) currentWeek(monday time.Time) (*week, error) {
+	if g.Week == nil || !g.Week.Monday.Equal(monday) {
+		return nil, fmt.Errorf("those pairings are out of date")
+	}
+	return g.Week, nil
+}
+
+// result rebuilds a Result from the stored week. Members who have since left
+// the team keep their user ID with an empty name.
+func (g *store) result() Result {
+	member := func(userID string) *Member {
+		for _, m := range g.Members {
+			if m.UserID == userID {
+				c := *m
+				return &c
+			}
+		}
+		return &Member{UserID: userID}
+	}
+
+	w := g.Week
+	result := Result{Week: w.Monday, Done: slices.Clone(w.Done), OnPTO: onPTO(g.Members, w.Monday)}
+	for _, p := range w.Pairs {
+		result.Pairs = append(result.Pairs, Pair{A: member(p[0]), B: member(p[1])})
+	}
+	if w.SittingOut != "" {
+		result.SittingOut = member(w.SittingOut)
+	}
+	return result
+}
+
+// forget drops the members' acknowledgements and done marks, after their
+// pairing changes.
+func (w *week) forget(userIDs ...string) {
+	drop := func(id string) bool { return slices.Contains(userIDs, id) }
+	w.Acked = slices.DeleteFunc(w.Acked, drop)
+	w.Done = slices.DeleteFunc(w.Done, drop)
+}
+
+// pairOf returns the index of the pair containing userID and which side of it
+// they are on, or -1 if they aren't paired.
+func (w *week) pairOf(userID string) (idx, side int) {
+	for i, p := range w.Pairs {
+		for j, id := range p {
+			if id == userID {
+				return i, j
+			}
+		}
+	}
+	return -1, 0
+}
@@ -0,0 +1,183 @@
+package prbuddy
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// generateWeek adds n members and generates a week of pairings for them.
+func generateWeek(t *testing.T, b *Bot, n int) Result {
+	t.Helper()
+	addTeam(b, "g1", n)
+	return b.Generate("g1", time.Date(2026, 4, 8, 0, 0, 0, 0, time.UTC))
+}
+
+// pairedWith returns userID's buddy in result, or "" if they aren't paired.
+func pairedWith(result Result, userID string) string {
+	for _, p := range result.Pairs {
+		switch userID {
+		case p.A.UserID:
+			return p.B.UserID
+		case p.B.UserID:
+			return p.A.UserID
+		}
+	}
+	return ""
+}
+
+// --- CurrentWeek ------------------------------------------------------------
+
+func TestCurrentWeek(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	if _, ok := b.CurrentWeek("g1"); ok {
+		t.Error("expected no week before Generate")
+	}
+
+	generated := generateWeek(t, b, 4)
+	got, ok := b.CurrentWeek("g1")
+	if !ok {
+		t.Fatal("expected a week after Generate")
+	}
+	if !got.Week.Equal(generated.Week) || len(got.Pairs) != 2 {
+		t.Errorf("unexpected week: %+v", got)
+	}
+}
+
+func TestGenerate_KeepsAdjustedWeek(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 6)
+	swapper := generated.Pairs[0].A.UserID
+	if _, err := b.Swap("g1", swapper, generated.Week); err != nil {
+		t.Fatalf("Swap: %v", err)
+	}
+	adjusted, err := b.MarkDone("g1", generated.Pairs[2].A.UserID, generated.Week)
+	if err != nil {
+		t.Fatalf("MarkDone: %v", err)
+	}
+
+	// A second run in the same week, e.g. an admin's /prbuddy generate or a
+	// restart catching up on the schedule, keeps the week as it stands.
+	again := b.Generate("g1", generated.Week.AddDate(0, 0, 2))
+	if !slices.Equal(pairIDs(again), pairIDs(adjusted)) {
+		t.Errorf("pairs: got %v, want %v", pairIDs(again), pairIDs(adjusted))
+	}
+	if !slices.Equal(again.Done, adjusted.Done) {
+		t.Errorf("done: got %v, want %v", again.Done, adjusted.Done)
+	}
+}
+
+// --- Swap -------------------------------------------------------------------
+
+func TestSwap(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 4)
+	user := generated.Pairs[0].A.UserID
+	before := pairedWith(generated, user)
+
+	got, err := b.Swap("g1", user, generated.Week)
+	if err != nil {
+		t.Fatalf("Swap: %v", err)
+	}
+	if after := pairedWith(got, user); after == "" || after == before {
+		t.Errorf("want a new buddy for %s, had %s, got %q", user, before, after)
+	}
+	if len(got.Pairs) != 2 {
+		t.Errorf("want 2 pairs after swap, got %d", len(got.Pairs))
+	}
+}
+
+func TestSwap_OnlyOnePair_Error(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 2)
+	if _, err := b.Swap("g1", generated.Pairs[0].A.UserID, generated.Week); err == nil {
+		t.Error("expected error with no other pair")
+	}
+}
+
+func TestSwap_StaleWeek_Error(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 4)
+	if _, err := b.Swap("g1", generated.Pairs[0].A.UserID, generated.Week.AddDate(0, 0, -7)); err == nil {
+		t.Error("expected error for last week's pairings")
+	}
+}
+
+// --- MarkOut ----------------------------------------------------------------
+
+func TestMarkOut_BuddySitsOut(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 4)
+	user, buddy := generated.Pairs[0].A.UserID, generated.Pairs[0].B.UserID
+
+	got, err := b.MarkOut("g1", user, generated.Week)
+	if err != nil {
+		t.Fatalf("MarkOut: %v", err)
+	}
+	if len(got.Pairs) != 1 {
+		t.Errorf("want 1 pair left, got %d", len(got.Pairs))
+	}
+	if got.SittingOut == nil || got.SittingOut.UserID != buddy {
+		t.Errorf("want %s sitting out, got %+v", buddy, got.SittingOut)
+	}
+}
+
+func TestMarkOut_BuddyPairsWithSittingOut(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 3)
+	user, buddy := generated.Pairs[0].A.UserID, generated.Pairs[0].B.UserID
+	sittingOut := generated.SittingOut.UserID
+
+	got, err := b.MarkOut("g1", user, generated.Week)
+	if err != nil {
+		t.Fatalf("MarkOut: %v", err)
+	}
+	if pairedWith(got, buddy) != sittingOut {
+		t.Errorf("want %s paired with %s, got %+v", buddy, sittingOut, got.Pairs)
+	}
+	if got.SittingOut != nil {
+		t.Errorf("want nobody sitting out, got %s", got.SittingOut.UserID)
+	}
+}
+
+// --- MarkDone ---------------------------------------------------------------
+
+func TestMarkDone(t *testing.T) {
+	b, cleanup := newTestBot(t)
+	defer cleanup()
+
+	generated := generateWeek(t, b, 2)
+	user := generated.Pairs[0].A.UserID
+
+	_, _ = b.MarkDone("g1", user, generated.Week)
+	got, err := b.MarkDone("g1", user, generated.Week)
+	if err != nil {
+		t.Fatalf("MarkDone: %v", err)
+	}
+	if !slices.Equal(got.Done, []string{user}) {
+		t.Errorf("want Done [%s], got %v", user, got.Done)
+	}
+
+	// The adjustment survives a restart.
+	reloaded, err := New(b.path, func(string, Result) {})
+	if err != nil {
+		t.Fatalf("New (reload): %v", err)
+	}
+	if week, _ := reloaded.CurrentWeek("g1"); !slices.Equal(week.Done, []string{user}) {
+		t.Errorf("after reload: want Done [%s], got %v", user, week.Done)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/config"
+)
+
+// ---------------------------------------------------------------------------
+// Shutdown
+// ---------------------------------------------------------------------------
+
+// handlers tracks Discord event handlers that are still running, so shutdown
+// can let them finish before closing the session.
+var handlers inflight
+
+// inflight counts running work and refuses new work once closed.
+type inflight struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// begin registers new work, returning false once shutdown has started.
+// Each successful begin must be matched by a call to done.
+func (f *inflight) begin() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	f.wg.Add(1)
+	return true
+}
+
+func (f *inflight) done() {
+	f.wg.Done()
+}
+
+// wait refuses new work and waits for running work to finish or for ctx to
+// be done, returning ctx's error in that case.
+func (f *inflight) wait(ctx context.Context) error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tracked wraps a Discord event handler so shutdown waits for it, and drops
+// events that arrive once shutdown has begun.
+func tracked[E any](handler func(*discordgo.Session, E)) func(*discordgo.Session, E) {
+	return func(s *discordgo.Session, event E) {
+		if !handlers.begin() {
+			return
+		}
+		defer handlers.done()
+		handler(s, event)
+	}
+}
+
+// shutdown stops background work and waits for running handlers and jobs,
+// then leaves, shows or hides desks as configured and lets the desk edit
+// queue drain. Everything shares one deadline, cfg.Shutdown.Timeout, so a
+// stuck job can't hold up a restart.
+func shutdown(s *discordgo.Session, stopHTTPServer, stopIdleSweeper func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+
+	stopHTTPServer()
+	stopIdleSweeper()
+	if cfg.Features.PRBuddy {
+		if err := buddy.Stop(ctx); err != nil {
+			logger.Warn("Gave up waiting for the PR buddy scheduler", "err", err)
+		}
+	}
+	if err := handlers.wait(ctx); err != nil {
+		logger.Warn("Gave up waiting for event handlers", "err", err)
+	}
+	if cfg.Features.Desks {
+		endSession(ctx, s, cfg.Shutdown.Desks)
+	}
+	if err := deskEdits.Close(ctx); err != nil {
+		logger.Warn("Gave up on queued desk edits", "err", err)
+	}
+	if cfg.DevGuild != "" {
+		unregisterCommands(s, cfg.DevGuild)
+	}
+}
+
+// endSession applies the configured shutdown behaviour to every guild's
+// desks, stopping early if ctx is done.
+func endSession(ctx context.Context, s *discordgo.Session, mode string) {
+	if mode == config.ShutdownLeave {
+		return
+	}
+	for _, guild := range s.State.Guilds {
+		if ctx.Err() != nil {
+			logger.Warn("Ran out of time updating desks on shutdown", "guild", guild.ID, "mode", mode)
+			return
+		}
+		switch mode {
+		case config.ShutdownShow:
+			showAllDeskChannels(ctx, s, guild)
+		case config.ShutdownHideEmpty:
+			hideEmptyDeskChannels(ctx, s, guild)
+		}
+	}
+}
+
+// hideEmptyDeskChannels hides every desk in the guild that nobody is in.
+func hideEmptyDeskChannels(ctx context.Context, s *discordgo.Session, guild *discordgo.Guild) {
+	maybeDeskCategoryId, ok := guildToDeskCategory.Load(guild.ID)
+	if !ok {
+		return
+	}
+	deskCategoryId := maybeDeskCategoryId.(string)
+
+	for _, channel := range guild.Channels {
+		if ctx.Err() != nil {
+			return
+		}
+		if channel.ParentID != deskCategoryId || deskOccupied(guild.ID, channel.ID) {
+			continue
+		}
+		if deskOwner(guild.ID, channel.ID) != "" {
+			hideDeskChannel(s, guild, channel)
+		}
+	}
+}
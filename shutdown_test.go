@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestInflight_WaitsForRunningWork(t *testing.T) {
+	var f inflight
+	if !f.begin() {
+		t.Fatal("begin refused before shutdown")
+	}
+
+	waited := make(chan error)
+	go func() { waited <- f.wait(context.Background()) }()
+
+	select {
+	case err := <-waited:
+		t.Fatalf("wait returned before work finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	f.done()
+	if err := <-waited; err != nil {
+		t.Errorf("wait: %v", err)
+	}
+}
+
+func TestInflight_RefusesWorkOnceWaiting(t *testing.T) {
+	var f inflight
+	if err := f.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if f.begin() {
+		t.Error("begin accepted work after shutdown started")
+	}
+}
+
+func TestInflight_Deadline(t *testing.T) {
+	var f inflight
+	f.begin()
+	defer f.done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := f.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestTracked(t *testing.T) {
+	handlers = inflight{}
+	defer func() { handlers = inflight{} }()
+
+	release := make(chan struct{})
+	ran := make(chan struct{}, 2)
+	handler := tracked(func(s *discordgo.Session, event *discordgo.Ready) {
+		ran <- struct{}{}
+		<-release
+	})
+
+	go handler(nil, &discordgo.Ready{})
+	<-ran
+
+	waited := make(chan error)
+	go func() { waited <- handlers.wait(context.Background()) }()
+
+	// Once shutdown has begun, new events are dropped.
+	for handlers.begin() {
+		handlers.done()
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	handler(nil, &discordgo.Ready{})
+
+	if err := <-waited; err != nil {
+		t.Errorf("wait: %v", err)
+	}
+	if len(ran) != 0 {
+		t.Error("handler ran after shutdown began")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cbarber/deskbot/desks"
+)
+
+// ---------------------------------------------------------------------------
+// Desk status board
+// ---------------------------------------------------------------------------
+
+// statusBoardDebounce is how long voice events are collected before the
+// status board is edited, to stay well clear of Discord's rate limits.
+const statusBoardDebounce = 5 * time.Second
+
+var (
+	statusBoardMutex  sync.Mutex
+	statusBoardTimers = make(map[string]*time.Timer) // guild ID → pending update
+)
+
+// scheduleStatusBoardUpdate coalesces presence changes into at most one
+// status board edit per guild every statusBoardDebounce.
+func scheduleStatusBoardUpdate(s *discordgo.Session, guildID string) {
+	if channelID, _ := deskStore.StatusBoard(guildID); channelID == "" {
+		return
+	}
+
+	statusBoardMutex.Lock()
+	defer statusBoardMutex.Unlock()
+
+	if _, pending := statusBoardTimers[guildID]; pending {
+		return
+	}
+	statusBoardTimers[guildID] = time.AfterFunc(statusBoardDebounce, func() {
+		statusBoardMutex.Lock()
+		delete(statusBoardTimers, guildID)
+		statusBoardMutex.Unlock()
+
+		updateStatusBoard(s, guildID)
+	})
+}
+
+// updateStatusBoard edits the guild's status board to match current presence.
+func updateStatusBoard(s *discordgo.Session, guildID string) {
+	channelID, messageID := deskStore.StatusBoard(guildID)
+	if channelID == "" {
+		return
+	}
+	if _, err := s.ChannelMessageEdit(channelID, messageID, renderStatusBoard(guildID)); err != nil {
+		logger.Error("Failed to update desk status board", "guild", guildID, "channel", channelID, "err", err)
+	}
+}
+
+// setupStatusBoard posts and pins a new status board in channelID, replacing
+// any previous board for the guild.
+func setupStatusBoard(s *discordgo.Session, guildID, channelID string) error {
+	oldChannelID, oldMessageID := deskStore.StatusBoard(guildID)
+
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         renderStatusBoard(guildID),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		return fmt.Errorf("post status board: %w", err)
+	}
+	if err := s.ChannelMessagePin(channelID, msg.ID); err != nil {
+		return fmt.Errorf("pin status board: %w", err)
+	}
+
+	if oldMessageID != "" {
+		if err := s.ChannelMessageUnpin(oldChannelID, oldMessageID); err != nil {
+			logger.Warn("Failed to unpin old desk status board", "guild", guildID, "channel", oldChannelID, "message", oldMessageID, "err", err)
+		}
+	}
+	return deskStore.SetStatusBoard(guildID, channelID, msg.ID)
+}
+
+// removeStatusBoard unpins the guild's status board and stops updating it.
+func removeStatusBoard(s *discordgo.Session, guildID string) error {
+	channelID, messageID := deskStore.StatusBoard(guildID)
+	if messageID != "" {
+		if err := s.ChannelMessageUnpin(channelID, messageID); err != nil {
+			logger.Warn("Failed to unpin desk status board", "guild", guildID, "channel", channelID, "message", messageID, "err", err)
+		}
+	}
+	return deskStore.SetStatusBoard(guildID, "", "")
+}
+
+func renderStatusBoard(guildID string) string {
+	owners := make(map[string]string)
+	for _, desk := range deskStore.Desks(guildID) {
+		owners[desk.ChannelID] = desk.OwnerID
+	}
+	return desks.FormatStatusBoard(presence.Seats(guildID), owners)
+}